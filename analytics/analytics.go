@@ -0,0 +1,269 @@
+// Package analytics computes averages, trends and distributions of marks
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// MarkType guessed from the lesson subject
+type MarkType int
+
+const (
+	// Regular mark for a lesson
+	Regular MarkType = iota
+	// Control work (контрольная работа)
+	Control
+	// Independent work (самостоятельная работа)
+	Independent
+	// Test (тест, проверочная работа, зачёт)
+	Test
+	// Dictation (диктант)
+	Dictation
+	// Essay (сочинение, изложение)
+	Essay
+	// Practical or laboratory work
+	Practical
+)
+
+func (t MarkType) String() string {
+	return [...]string{"regular", "control", "independent", "test", "dictation", "essay", "practical"}[t]
+}
+
+var typeKeywords = []struct {
+	t        MarkType
+	keywords []string
+}{
+	{Control, []string{"контрольн", "к/р", "итоговая работа", "срез"}},
+	{Independent, []string{"самостоятельн", "с/р"}},
+	{Test, []string{"тест", "проверочн", "зачёт", "зачет"}},
+	{Dictation, []string{"диктант"}},
+	{Essay, []string{"сочинени", "изложени"}},
+	{Practical, []string{"лабораторн", "практическ"}},
+}
+
+// TypeOf mark by keywords of the lesson subject
+func TypeOf(m dnevnik76.Mark) MarkType {
+	subject := strings.ToLower(m.Subject)
+	for _, tk := range typeKeywords {
+		for _, k := range tk.keywords {
+			if strings.Contains(subject, k) {
+				return tk.t
+			}
+		}
+	}
+	return Regular
+}
+
+// Weights of mark types, missing types weigh 1
+type Weights map[MarkType]float64
+
+// DefaultWeights commonly used for weighted averages
+var DefaultWeights = Weights{Control: 2, Test: 1.5, Dictation: 1.5, Essay: 1.5}
+
+func (w Weights) of(m dnevnik76.Mark) float64 {
+	if v, ok := w[TypeOf(m)]; ok {
+		return v
+	}
+	return 1
+}
+
+// numeric grades of mark, attendance and other non-numeric marks are skipped
+func numeric(m dnevnik76.Mark) (grades []float64) {
+	for _, g := range m.Grade {
+		if g >= 1 && g <= 5 {
+			grades = append(grades, float64(g))
+		}
+	}
+	return
+}
+
+// Average of one course
+type Average struct {
+	CourseName string  `json:"courseName"`
+	Count      int     `json:"count"`
+	Mean       float64 `json:"mean"`
+	Weighted   float64 `json:"weighted"`
+}
+
+// CourseAverages of numeric grades per course, weights may be nil
+func CourseAverages(marks []dnevnik76.Mark, weights Weights) []Average {
+	type acc struct {
+		n            int
+		sum, wsum, w float64
+	}
+	accs := map[string]*acc{}
+	var order []string
+	for _, m := range marks {
+		grades := numeric(m)
+		if len(grades) == 0 {
+			continue
+		}
+		course := strings.TrimSpace(m.CourseName)
+		a, ok := accs[course]
+		if !ok {
+			a = &acc{}
+			accs[course] = a
+			order = append(order, course)
+		}
+		w := weights.of(m)
+		for _, g := range grades {
+			a.n++
+			a.sum += g
+			a.wsum += g * w
+			a.w += w
+		}
+	}
+	sort.Strings(order)
+	avgs := make([]Average, 0, len(order))
+	for _, c := range order {
+		a := accs[c]
+		avg := Average{CourseName: c, Count: a.n, Mean: a.sum / float64(a.n)}
+		if a.w > 0 {
+			avg.Weighted = a.wsum / a.w
+		}
+		avgs = append(avgs, avg)
+	}
+	return avgs
+}
+
+// PeriodAverages of one period
+type PeriodAverages struct {
+	Period   dnevnik76.Lperiod `json:"period"`
+	Averages []Average         `json:"averages"`
+}
+
+// ByPeriod to get course averages within every period
+func ByPeriod(marks []dnevnik76.Mark, periods dnevnik76.Periods, weights Weights) (result []PeriodAverages) {
+	for _, p := range periods {
+		var in []dnevnik76.Mark
+		for _, m := range marks {
+			if p.Contains(m.Date) {
+				in = append(in, m)
+			}
+		}
+		result = append(result, PeriodAverages{Period: p, Averages: CourseAverages(in, weights)})
+	}
+	return
+}
+
+// TrendPoint of a course at a lesson date
+type TrendPoint struct {
+	Date    time.Time `json:"date"`
+	Mean    float64   `json:"mean"`
+	Rolling float64   `json:"rolling"`
+}
+
+// Trend of course with rolling mean over the last window grades
+func Trend(marks []dnevnik76.Mark, course string, window int) (points []TrendPoint) {
+	if window < 1 {
+		window = 1
+	}
+	sorted := append([]dnevnik76.Mark(nil), marks...)
+	sort.Stable(dnevnik76.MarksByDate(sorted))
+	var all []float64
+	for _, m := range sorted {
+		if strings.TrimSpace(m.CourseName) != strings.TrimSpace(course) {
+			continue
+		}
+		grades := numeric(m)
+		if len(grades) == 0 {
+			continue
+		}
+		all = append(all, grades...)
+		last := all
+		if len(last) > window {
+			last = last[len(last)-window:]
+		}
+		p := TrendPoint{Date: m.Date, Mean: mean(grades), Rolling: mean(last)}
+		if n := len(points); n > 0 && points[n-1].Date.Equal(m.Date) {
+			points[n-1] = p
+			continue
+		}
+		points = append(points, p)
+	}
+	return
+}
+
+// Slope of rolling trend per point, positive when marks are improving
+func Slope(points []TrendPoint) float64 {
+	n := float64(len(points))
+	if n < 2 {
+		return 0
+	}
+	var sx, sy, sxy, sxx float64
+	for i, p := range points {
+		x := float64(i)
+		sx += x
+		sy += p.Rolling
+		sxy += x * p.Rolling
+		sxx += x * x
+	}
+	return (n*sxy - sx*sy) / (n*sxx - sx*sx)
+}
+
+// Distribution of grades 1 to 5 of one course
+type Distribution struct {
+	CourseName string `json:"courseName"`
+	// Counts by grade, Counts[5] is the number of fives
+	Counts [6]int `json:"counts"`
+}
+
+// Distributions of grades per course
+func Distributions(marks []dnevnik76.Mark) (result []Distribution) {
+	index := map[string]int{}
+	for _, m := range marks {
+		course := strings.TrimSpace(m.CourseName)
+		for _, g := range numeric(m) {
+			i, ok := index[course]
+			if !ok {
+				i = len(result)
+				index[course] = i
+				result = append(result, Distribution{CourseName: course})
+			}
+			result[i].Counts[int(g)]++
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CourseName < result[j].CourseName })
+	return
+}
+
+// Comparison of local and server-side average of course
+type Comparison struct {
+	CourseName string  `json:"courseName"`
+	Local      float64 `json:"local"`
+	Server     float64 `json:"server"`
+	Diff       float64 `json:"diff"`
+}
+
+// Compare local averages with the ones shown by the site (Client.GetMarksAverages)
+func Compare(local []Average, server []dnevnik76.CourseAverage) (result []Comparison) {
+	byCourse := map[string]float64{}
+	for _, a := range local {
+		byCourse[a.CourseName] = a.Mean
+	}
+	for _, s := range server {
+		course := strings.TrimSpace(s.CourseName)
+		l, ok := byCourse[course]
+		if !ok {
+			continue
+		}
+		result = append(result, Comparison{CourseName: course, Local: l, Server: s.Average, Diff: math.Round((l-s.Average)*100) / 100})
+	}
+	return
+}
+
+func mean(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2022, m, d, 0, 0, 0, 0, time.Local)
+}
+
+var marks = []dnevnik76.Mark{
+	{CourseName: "Алгебра", Date: day(9, 5), Grade: []int8{5, 4}},
+	{CourseName: "Алгебра", Date: day(9, 12), Grade: []int8{3}, Subject: "Контрольная работа №1"},
+	{CourseName: "Алгебра", Date: day(9, 14), Grade: []int8{0}},
+	{CourseName: "Алгебра", Date: day(11, 10), Grade: []int8{5}},
+	{CourseName: "Физика ", Date: day(9, 6), Grade: []int8{4}},
+	{CourseName: "Физика", Date: day(9, 7)},
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTypeOf(t *testing.T) {
+	cases := map[string]MarkType{
+		"Контрольная работа №1":  Control,
+		"Самостоятельная работа": Independent,
+		"Словарный диктант":      Dictation,
+		"Лабораторная работа №2": Practical,
+		"Проверочная работа":     Test,
+		"Квадратные уравнения":   Regular,
+	}
+	for subject, want := range cases {
+		if got := TypeOf(dnevnik76.Mark{Subject: subject}); got != want {
+			t.Errorf("%q - %s, want %s", subject, got, want)
+		}
+	}
+}
+
+func TestCourseAverages(t *testing.T) {
+	avgs := CourseAverages(marks, DefaultWeights)
+	if len(avgs) != 2 {
+		t.Fatalf("averages - %+v", avgs)
+	}
+	alg := avgs[0]
+	if alg.CourseName != "Алгебра" || alg.Count != 4 || !near(alg.Mean, 17.0/4) || !near(alg.Weighted, 20.0/5) {
+		t.Errorf("algebra - %+v", alg)
+	}
+	if avgs[1].CourseName != "Физика" || avgs[1].Mean != 4 {
+		t.Errorf("physics - %+v", avgs[1])
+	}
+	if plain := CourseAverages(marks, nil); plain[0].Weighted != plain[0].Mean {
+		t.Errorf("unweighted - %+v", plain[0])
+	}
+}
+
+func TestByPeriod(t *testing.T) {
+	periods := dnevnik76.NewPeriods([]dnevnik76.Lperiod{
+		{Name: "1 четверть", Start: day(9, 1), End: day(10, 28)},
+		{Name: "2 четверть", Start: day(11, 7), End: day(12, 28)},
+	})
+	pa := ByPeriod(marks, periods, nil)
+	if len(pa) != 2 || len(pa[0].Averages) != 2 || len(pa[1].Averages) != 1 || pa[1].Averages[0].Mean != 5 {
+		t.Errorf("by period - %+v", pa)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	points := Trend(marks, "Алгебра", 2)
+	if len(points) != 3 {
+		t.Fatalf("points - %+v", points)
+	}
+	if points[0].Mean != 4.5 || points[1].Rolling != 3.5 || points[2].Rolling != 4 {
+		t.Errorf("points - %+v", points)
+	}
+	if s := Slope(points); !near(s, -0.25) {
+		t.Errorf("slope - %v", s)
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	d := Distributions(marks)
+	if len(d) != 2 || d[0].Counts != [6]int{0, 0, 0, 1, 1, 2} {
+		t.Errorf("distributions - %+v", d)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	c := Compare(CourseAverages(marks, nil), []dnevnik76.CourseAverage{
+		{CourseName: "Алгебра", Average: 4.5},
+		{CourseName: "Химия", Average: 5},
+	})
+	if len(c) != 1 || c[0].Diff != -0.25 {
+		t.Errorf("comparison - %+v", c)
+	}
+}
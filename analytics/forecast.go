@@ -0,0 +1,179 @@
+// Package analytics forecast
+package analytics
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+const eps = 1e-9
+
+// ErrNoPeriod is returned when no term contains the forecast date
+var ErrNoPeriod = errors.New("analytics: no term for the date")
+
+// Rounding rule of the school for the final mark
+type Rounding struct {
+	// Threshold is the fraction of average rounded up to the next grade,
+	// 0.5 gives 4.5 → 5, 0.6 gives 4.6 → 5, zero means 0.5
+	Threshold float64
+	// Minimum average per grade overriding Threshold, e.g. {5: 4.6, 4: 3.5}
+	Minimum map[int]float64
+}
+
+// DefaultRounding is the arithmetic rounding, 4.5 → 5
+var DefaultRounding = Rounding{Threshold: 0.5}
+
+// MinAverage to get grade under the rule
+func (r Rounding) MinAverage(grade int) float64 {
+	if v, ok := r.Minimum[grade]; ok {
+		return v
+	}
+	if grade <= 1 {
+		return 0
+	}
+	t := r.Threshold
+	if t <= 0 {
+		t = 0.5
+	}
+	return float64(grade-1) + t
+}
+
+// Round average to grade, zero average gives no grade
+func (r Rounding) Round(avg float64) int {
+	if avg <= 0 {
+		return 0
+	}
+	for g := 5; g > 1; g-- {
+		if avg+eps >= r.MinAverage(g) {
+			return g
+		}
+	}
+	return 1
+}
+
+// Forecast of the final mark of course for period
+type Forecast struct {
+	CourseName string            `json:"courseName"`
+	Period     dnevnik76.Lperiod `json:"period"`
+	Count      int               `json:"count"`
+	Average    float64           `json:"average"`
+	Mark       int               `json:"mark"`
+	Target     int               `json:"target"`
+	// Needed is the smallest set of further grades to reach Target, empty when reached
+	Needed []int `json:"needed"`
+	// Reachable is false when Target can't be reached within MaxMarks further grades
+	Reachable bool `json:"reachable"`
+}
+
+// Source of periods and marks, satisfied by *dnevnik76.Client
+type Source interface {
+	GetMarksPeriods() ([]dnevnik76.Lperiod, error)
+	GetMarksForWithType(p string, t dnevnik76.MarksListType) ([]dnevnik76.Mark, error)
+}
+
+// Forecaster to predict final marks and what is needed for the target
+type Forecaster struct {
+	Rounding Rounding
+	// Weights of existing marks, further marks are counted as regular ones
+	Weights Weights
+	// Target grade, zero means 5
+	Target int
+	// MaxMarks is the limit of further grades considered, zero means 10
+	MaxMarks int
+}
+
+// Current to forecast the term containing date using marks from the site
+func (f Forecaster) Current(src Source, date time.Time) (forecasts []Forecast, err error) {
+	periods, err := src.GetMarksPeriods()
+	if err != nil {
+		return
+	}
+	p, ok := dnevnik76.NewPeriods(periods).Terms().PeriodAt(date)
+	if !ok {
+		return nil, ErrNoPeriod
+	}
+	marks, err := src.GetMarksForWithType(p.Period, dnevnik76.Note)
+	if err != nil {
+		return
+	}
+	forecasts = f.Forecast(marks, p)
+	return
+}
+
+// Forecast final marks of every course from marks within period
+func (f Forecaster) Forecast(marks []dnevnik76.Mark, period dnevnik76.Lperiod) (forecasts []Forecast) {
+	var in []dnevnik76.Mark
+	for _, m := range marks {
+		if period.Start.IsZero() || period.Contains(m.Date) {
+			in = append(in, m)
+		}
+	}
+	byCourse := map[string][]dnevnik76.Mark{}
+	for _, m := range in {
+		c := strings.TrimSpace(m.CourseName)
+		byCourse[c] = append(byCourse[c], m)
+	}
+	for _, a := range CourseAverages(in, f.Weights) {
+		fc := Forecast{
+			CourseName: a.CourseName,
+			Period:     period,
+			Count:      a.Count,
+			Average:    a.Weighted,
+			Mark:       f.Rounding.Round(a.Weighted),
+			Target:     f.target(),
+		}
+		fc.Needed, fc.Reachable = f.Need(byCourse[a.CourseName], fc.Target)
+		forecasts = append(forecasts, fc)
+	}
+	return
+}
+
+// Need to get the smallest set of further grades for marks of one course to reach target,
+// fewest grades first and then the lowest ones, no grade below 2 is suggested
+func (f Forecaster) Need(marks []dnevnik76.Mark, target int) (grades []int, ok bool) {
+	var sum, weight float64
+	for _, m := range marks {
+		w := f.Weights.of(m)
+		for _, g := range numeric(m) {
+			sum += g * w
+			weight += w
+		}
+	}
+	min := f.Rounding.MinAverage(target)
+	if min <= 0 || weight > 0 && sum/weight+eps >= min {
+		return nil, true
+	}
+	max := f.MaxMarks
+	if max <= 0 {
+		max = 10
+	}
+	for n := 1; n <= max; n++ {
+		need := min*(weight+float64(n)) - sum
+		if float64(5*n)+eps < need {
+			continue
+		}
+		grades = make([]int, n)
+		total := 5 * n
+		for i := range grades {
+			grades[i] = 5
+		}
+		for i := n - 1; i >= 0; i-- {
+			for grades[i] > 2 && float64(total-1)+eps >= need {
+				grades[i]--
+				total--
+			}
+		}
+		return grades, true
+	}
+	return nil, false
+}
+
+func (f Forecaster) target() int {
+	if f.Target < 1 || f.Target > 5 {
+		return 5
+	}
+	return f.Target
+}
@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func grades(course string, date time.Time, g ...int8) dnevnik76.Mark {
+	return dnevnik76.Mark{CourseName: course, Date: date, Grade: g}
+}
+
+func TestRounding_Round(t *testing.T) {
+	strict := Rounding{Threshold: 0.6}
+	custom := Rounding{Minimum: map[int]float64{5: 4.75}}
+	cases := []struct {
+		r    Rounding
+		avg  float64
+		want int
+	}{
+		{DefaultRounding, 4.5, 5},
+		{DefaultRounding, 4.49, 4},
+		{strict, 4.5, 4},
+		{strict, 4.6, 5},
+		{custom, 4.7, 4},
+		{custom, 3.5, 4},
+		{Rounding{}, 2.5, 3},
+		{DefaultRounding, 1.2, 1},
+		{DefaultRounding, 0, 0},
+	}
+	for _, c := range cases {
+		if got := c.r.Round(c.avg); got != c.want {
+			t.Errorf("%+v round %v - %d, want %d", c.r, c.avg, got, c.want)
+		}
+	}
+}
+
+func TestForecaster_Need(t *testing.T) {
+	d := day(9, 5)
+	marks := []dnevnik76.Mark{grades("Алгебра", d, 4, 4), grades("Алгебра", d, 5)}
+	cases := []struct {
+		f      Forecaster
+		target int
+		want   []int
+		ok     bool
+	}{
+		{Forecaster{}, 5, []int{5}, true},
+		{Forecaster{Rounding: Rounding{Threshold: 0.6}}, 5, []int{5, 5}, true},
+		{Forecaster{}, 4, nil, true},
+		{Forecaster{MaxMarks: 1, Rounding: Rounding{Threshold: 0.9}}, 5, nil, false},
+	}
+	for _, c := range cases {
+		got, ok := c.f.Need(marks, c.target)
+		if ok != c.ok || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v target %d - %v (%t), want %v (%t)", c.f.Rounding, c.target, got, ok, c.want, c.ok)
+		}
+	}
+
+	got, _ := Forecaster{}.Need([]dnevnik76.Mark{grades("Физика", d, 4, 3, 3)}, 4)
+	if !reflect.DeepEqual(got, []int{4}) {
+		t.Errorf("lowest set - %v, want [4]", got)
+	}
+}
+
+type fakeSource struct {
+	periods []dnevnik76.Lperiod
+	marks   map[string][]dnevnik76.Mark
+}
+
+func (s fakeSource) GetMarksPeriods() ([]dnevnik76.Lperiod, error) {
+	return s.periods, nil
+}
+
+func (s fakeSource) GetMarksForWithType(p string, _ dnevnik76.MarksListType) ([]dnevnik76.Mark, error) {
+	return s.marks[p], nil
+}
+
+func TestForecaster_Current(t *testing.T) {
+	src := fakeSource{
+		periods: []dnevnik76.Lperiod{
+			{Name: "Сентябрь", Period: "month9", Start: day(9, 1), End: day(9, 30)},
+			{Name: "1 четверть", Period: "q1", Start: day(9, 1), End: day(10, 28)},
+		},
+		marks: map[string][]dnevnik76.Mark{"q1": {
+			grades("Алгебра", day(9, 5), 5, 4),
+			grades("Физика", day(9, 6), 3),
+		}},
+	}
+	f := Forecaster{Target: 5}
+	forecasts, err := f.Current(src, day(9, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forecasts) != 2 || forecasts[0].Period.Period != "q1" {
+		t.Fatalf("forecasts - %+v", forecasts)
+	}
+	if fc := forecasts[0]; fc.Mark != 5 || fc.Needed != nil || !fc.Reachable {
+		t.Errorf("algebra - %+v", fc)
+	}
+	if fc := forecasts[1]; fc.Mark != 3 || !reflect.DeepEqual(fc.Needed, []int{5, 5, 5}) {
+		t.Errorf("physics - %+v", fc)
+	}
+	if _, err = f.Current(src, day(11, 1)); err != ErrNoPeriod {
+		t.Errorf("holidays - %v, want ErrNoPeriod", err)
+	}
+}
@@ -0,0 +1,334 @@
+// Package dnevnik76 archive
+package dnevnik76
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ArchiveVersion of the archive bundle format
+const ArchiveVersion = 1
+
+// ArchiveFormat of the archive output
+type ArchiveFormat int
+
+const (
+	// ArchiveJSON writes the bundle as a single JSON document
+	ArchiveJSON ArchiveFormat = iota
+	// ArchiveNDJSON writes one JSON record per line
+	ArchiveNDJSON
+)
+
+// ArchiveBundle with everything collected for the user
+type ArchiveBundle struct {
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Login     string        `json:"login"`
+	SchoolID  int64         `json:"schoolId"`
+	Years     []ArchiveYear `json:"years"`
+	Messages  []Message     `json:"messages"`
+}
+
+// ArchiveYear with data of one academic year
+type ArchiveYear struct {
+	Year     EduYear     `json:"year"`
+	Info     CurrentInfo `json:"info"`
+	Periods  []Lperiod   `json:"periods"`
+	Courses  []Course    `json:"courses"`
+	Marks    []Mark      `json:"marks"`
+	Final    []Mark      `json:"final"`
+	Homework []Homework  `json:"homework"`
+	Teachers []Teacher   `json:"teachers"`
+}
+
+// ArchiveProgress reported after every archive step
+type ArchiveProgress struct {
+	Year  EduYear `json:"year"`
+	Step  string  `json:"step"`
+	Done  int     `json:"done"`
+	Total int     `json:"total"`
+}
+
+// Archiver to export every academic year available to the user
+type Archiver struct {
+	Client *Client
+	Format ArchiveFormat
+	// Progress is called after every finished step
+	Progress func(ArchiveProgress)
+	// Checkpoint file keeps finished parts so a failed run resumes from there
+	Checkpoint string
+}
+
+// archiveRecord is a line of NDJSON archive
+type archiveRecord struct {
+	Type string      `json:"type"`
+	Year EduYear     `json:"year,omitempty"`
+	Data interface{} `json:"data"`
+}
+
+// archiveCheckpoint is the state of an unfinished archive run
+type archiveCheckpoint struct {
+	Years        []ArchiveYear `json:"years"`
+	Messages     []Message     `json:"messages"`
+	MessagesDone bool          `json:"messagesDone"`
+}
+
+// Archive to write JSON bundle of every academic year to w
+func (cli *Client) Archive(ctx context.Context, w io.Writer) error {
+	a := Archiver{Client: cli}
+	return a.Archive(ctx, w)
+}
+
+// Archive to collect every academic year and write the bundle to w
+func (a *Archiver) Archive(ctx context.Context, w io.Writer) (err error) {
+	cp, err := a.loadCheckpoint()
+	if err != nil {
+		return
+	}
+
+	years, err := a.Client.AvailableYears()
+	if err != nil {
+		return
+	}
+	if len(years) == 0 {
+		years = []EduYear{a.Client.CurrentInfo.EduYear()}
+	}
+
+	done := map[EduYear]bool{}
+	for _, y := range cp.Years {
+		done[y.Year] = true
+	}
+	total := len(years) + 1
+	for i, y := range years {
+		if done[y] {
+			a.progress(ArchiveProgress{Year: y, Step: "resumed", Done: i + 1, Total: total})
+			continue
+		}
+		var ay ArchiveYear
+		ay, err = a.archiveYear(ctx, y, i, total)
+		if err != nil {
+			return fmt.Errorf("archive %s: %w", y, err)
+		}
+		cp.Years = append(cp.Years, ay)
+		if err = a.saveCheckpoint(cp); err != nil {
+			return
+		}
+	}
+
+	if !cp.MessagesDone {
+		if err = a.archiveMessages(ctx, &cp); err != nil {
+			return fmt.Errorf("archive messages: %w", err)
+		}
+	}
+	a.progress(ArchiveProgress{Step: "messages", Done: total, Total: total})
+
+	bundle := ArchiveBundle{
+		Version:   ArchiveVersion,
+		CreatedAt: time.Now(),
+		Login:     a.Client.Username,
+		SchoolID:  a.Client.SchoolID,
+		Years:     cp.Years,
+		Messages:  cp.Messages,
+	}
+	if err = a.write(w, bundle); err != nil {
+		return
+	}
+	if a.Checkpoint != "" {
+		err = os.Remove(a.Checkpoint)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	return
+}
+
+func (a *Archiver) archiveYear(ctx context.Context, y EduYear, i, total int) (ay ArchiveYear, err error) {
+	cli, err := a.Client.ForYear(y)
+	if err != nil {
+		return
+	}
+	ay = ArchiveYear{Year: y, Info: cli.CurrentInfo}
+
+	step := func(name string, f func() error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := f(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		a.progress(ArchiveProgress{Year: y, Step: name, Done: i, Total: total})
+		return nil
+	}
+
+	var periods Periods
+	steps := []struct {
+		name string
+		f    func() error
+	}{
+		{"periods", func() (err error) {
+			periods, err = cli.GetPeriods()
+			ay.Periods = periods
+			return
+		}},
+		{"courses", func() (err error) {
+			ay.Courses, err = cli.GetCourses()
+			return
+		}},
+		{"marks", func() error {
+			// months are the smallest periods, some schools list terms only
+			ps := periods.OfKind(PeriodMonth)
+			if len(ps) == 0 {
+				ps = periods.FinalTerms()
+			}
+			if len(ps) == 0 {
+				return errors.New("no month or term periods")
+			}
+			for _, p := range ps {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				marks, err := cli.GetMarksForWithType(p.Period, Note)
+				if err != nil {
+					return err
+				}
+				ay.Marks = append(ay.Marks, marks...)
+			}
+			return nil
+		}},
+		{"final", func() (err error) {
+			ay.Final, err = cli.GetMarksFinal()
+			return
+		}},
+		{"homework", func() (err error) {
+			ay.Homework, err = cli.GetHomework()
+			return
+		}},
+		{"teachers", func() (err error) {
+			ay.Teachers, err = cli.GetTeachers()
+			return
+		}},
+	}
+	for _, s := range steps {
+		if err = step(s.name, s.f); err != nil {
+			return
+		}
+	}
+	a.progress(ArchiveProgress{Year: y, Step: "done", Done: i + 1, Total: total})
+	return
+}
+
+func (a *Archiver) archiveMessages(ctx context.Context, cp *archiveCheckpoint) (err error) {
+	if cp.Messages == nil {
+		if cp.Messages, err = a.Client.GetMessages(); err != nil {
+			return
+		}
+	}
+	for i := range cp.Messages {
+		m := &cp.Messages[i]
+		if m.Body != "" {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		var full Message
+		if full, err = a.Client.GetMessage(m.ID); err != nil {
+			break
+		}
+		m.Body = full.Body
+	}
+	if err == nil {
+		cp.MessagesDone = true
+	}
+	if serr := a.saveCheckpoint(*cp); err == nil {
+		err = serr
+	}
+	return
+}
+
+func (a *Archiver) write(w io.Writer, bundle ArchiveBundle) error {
+	enc := json.NewEncoder(w)
+	if a.Format == ArchiveJSON {
+		enc.SetIndent("", "  ")
+		return enc.Encode(bundle)
+	}
+
+	header := map[string]interface{}{
+		"version":   bundle.Version,
+		"createdAt": bundle.CreatedAt,
+		"login":     bundle.Login,
+		"schoolId":  bundle.SchoolID,
+	}
+	records := []archiveRecord{{Type: "archive", Data: header}}
+	for _, y := range bundle.Years {
+		records = append(records, archiveRecord{Type: "year", Year: y.Year, Data: y.Info})
+		for _, v := range y.Periods {
+			records = append(records, archiveRecord{Type: "period", Year: y.Year, Data: v})
+		}
+		for _, v := range y.Courses {
+			records = append(records, archiveRecord{Type: "course", Year: y.Year, Data: v})
+		}
+		for _, v := range y.Marks {
+			records = append(records, archiveRecord{Type: "mark", Year: y.Year, Data: v})
+		}
+		for _, v := range y.Final {
+			records = append(records, archiveRecord{Type: "final", Year: y.Year, Data: v})
+		}
+		for _, v := range y.Homework {
+			records = append(records, archiveRecord{Type: "homework", Year: y.Year, Data: v})
+		}
+		for _, v := range y.Teachers {
+			records = append(records, archiveRecord{Type: "teacher", Year: y.Year, Data: v})
+		}
+	}
+	for _, v := range bundle.Messages {
+		records = append(records, archiveRecord{Type: "message", Data: v})
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archiver) progress(p ArchiveProgress) {
+	if a.Progress != nil {
+		a.Progress(p)
+	}
+}
+
+func (a *Archiver) loadCheckpoint() (cp archiveCheckpoint, err error) {
+	if a.Checkpoint == "" {
+		return
+	}
+	data, err := os.ReadFile(a.Checkpoint)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &cp)
+	return
+}
+
+func (a *Archiver) saveCheckpoint(cp archiveCheckpoint) error {
+	if a.Checkpoint == "" {
+		return nil
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := a.Checkpoint + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.Checkpoint)
+}
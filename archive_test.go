@@ -0,0 +1,192 @@
+package dnevnik76
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSite serves minimal pages for every academic year
+func fakeSite() http.Handler {
+	mux := http.NewServeMux()
+	year := func(r *http.Request) int {
+		y := 2022
+		if c, err := r.Cookie(cookieEduYear); err == nil {
+			fmt.Sscan(c.Value, &y)
+		}
+		return y
+	}
+	mux.HandleFunc("/homework/", func(w http.ResponseWriter, r *http.Request) {
+		y := year(r)
+		page := strings.Replace(homeworkPage(y), "</body>", fmt.Sprintf(`<div id="homework_list"><table class="list"><tbody>
+			<tr><td>5 сентября %d г.</td><td>Пн</td><td><a>Математика</a></td><td>№ %d</td><td>Дроби</td></tr>
+			</tbody></table></div></body>`, y, y), 1)
+		fmt.Fprint(w, page)
+	})
+	mux.HandleFunc("/marks/current/", func(w http.ResponseWriter, r *http.Request) {
+		y := year(r)
+		switch strings.TrimSuffix(r.URL.Path, "/") {
+		case "/marks/current":
+			fmt.Fprint(w, `<select id="mark_range"><optgroup label="Месяцы"><option value="month9">Сентябрь</option></optgroup>
+				<optgroup label="Четверти"><option value="q1">1 четверть</option></optgroup></select>`)
+		case "/marks/current/q1/note":
+			fmt.Fprintf(w, `<div id="content"><h3>с 1 сентября %d г. по 28 октября %d г.</h3></div>`, y, y)
+		case "/marks/current/month9/note":
+			fmt.Fprintf(w, `<div id="content"><h3>с 1 сентября %d г. по 30 сентября %d г.</h3></div>
+				<div id="marks"><div class="week"><div class="dayofweek">
+				<div class="weekday"><h3>Понедельник (5 сентября %d г.)</h3></div>
+				<table><tbody><tr title="Тема: Дроби"><td>Математика</td><td>№ 1</td><td class="col-mark"><span class="mark">5</span></td></tr></tbody></table>
+				</div></div></div>`, y, y, y)
+		}
+	})
+	mux.HandleFunc("/ajax/subj/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<select><option value="0">Все</option><option value="11">Математика</option></select>`)
+	})
+	mux.HandleFunc("/marks/itog/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="marks"><div id="wrap-col"><div id="wrap-marks"><div><div id="mark-row" name="11">
+			<span class="mark itg-q"><a onclick="showMarkItogInfo('1 четверть')">4</a></span>
+			<span class="mark itg-y"><a onclick="showMarkItogInfo('Год')">5</a></span></div></div></div></div></div>`)
+	})
+	mux.HandleFunc("/teachers/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="content"><table class="list"><tbody><tr><td></td><td>Иванова Мария Петровна</td><td><b>Математика</b></td>
+			<td class="action_links"><a class="mailto" href="/messages/new/?to=ivanova@760215"></a></td></tr></tbody></table></div>`)
+	})
+	mux.HandleFunc("/messages/input", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="content"><form><table class="list"><tbody><tr><td><input value="7"/></td>
+			<td><a class="unread">Собрание</a></td><td>Директор</td><td>17 декабря 2022 г. 18:09</td></tr></tbody></table></form></div>`)
+	})
+	mux.HandleFunc("/messages/input/7/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="msgview"><div class="msg-text">Собрание в пятницу</div></div>`)
+	})
+	return mux
+}
+
+// recordingSite remembers requested pages with their academic year
+type recordingSite struct {
+	next  http.Handler
+	calls []string
+}
+
+func (s *recordingSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	y := "current"
+	if c, err := r.Cookie(cookieEduYear); err == nil {
+		y = c.Value
+	}
+	s.calls = append(s.calls, y+" "+r.URL.Path)
+	s.next.ServeHTTP(w, r)
+}
+
+func TestArchiver_Resume(t *testing.T) {
+	site := &recordingSite{next: fakeSite()}
+	cli := newFakeClient(t, site)
+	checkpoint := filepath.Join(t.TempDir(), "archive.checkpoint")
+	a := Archiver{Client: cli, Format: ArchiveNDJSON, Checkpoint: checkpoint}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var steps []string
+	a.Progress = func(p ArchiveProgress) {
+		steps = append(steps, fmt.Sprintf("%d %s", p.Year, p.Step))
+		if p.Year == 2021 && p.Step == "final" {
+			cancel()
+		}
+	}
+	var buf bytes.Buffer
+	if err := a.Archive(ctx, &buf); err == nil {
+		t.Fatal("expected cancelled archive to fail")
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("checkpoint was not kept: %v", err)
+	}
+	if strings.Join(steps, ",") != "2022 periods,2022 courses,2022 marks,2022 final,2022 homework,2022 teachers,2022 done,2021 periods,2021 courses,2021 marks,2021 final" {
+		t.Errorf("progress - %v", steps)
+	}
+
+	site.calls = nil
+	a.Progress = nil
+	if err := a.Archive(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range site.calls {
+		if strings.HasPrefix(c, "2022 ") {
+			t.Errorf("finished year fetched again: %s", c)
+		}
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("checkpoint was not removed: %v", err)
+	}
+
+	types := map[string]int{}
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var r archiveRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		types[r.Type]++
+	}
+	want := map[string]int{"archive": 1, "year": 3, "period": 6, "course": 3, "mark": 3, "final": 6, "homework": 3, "teacher": 3, "message": 1}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("records - %v, want %v", types, want)
+	}
+}
+
+func TestArchiver_TermsOnly(t *testing.T) {
+	site := fakeSite()
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimSuffix(r.URL.Path, "/") {
+		case "/marks/current":
+			fmt.Fprint(w, `<select id="mark_range"><optgroup label="Четверти"><option value="q1">1 четверть</option></optgroup></select>`)
+		case "/marks/current/q1/note":
+			fmt.Fprint(w, `<div id="content"><h3>с 1 сентября 2022 г. по 28 октября 2022 г.</h3></div>
+				<div id="marks"><div class="week"><div class="dayofweek">
+				<div class="weekday"><h3>Понедельник (5 сентября 2022 г.)</h3></div>
+				<table><tbody><tr><td>Математика</td><td>№ 1</td><td class="col-mark"><span class="mark">5</span></td></tr></tbody></table>
+				</div></div></div>`)
+		default:
+			site.ServeHTTP(w, r)
+		}
+	}))
+	a := Archiver{Client: cli}
+	var buf bytes.Buffer
+	if err := a.Archive(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	var bundle ArchiveBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	for _, y := range bundle.Years {
+		if len(y.Marks) != 1 {
+			t.Errorf("%s: marks of terms - %d, want 1", y.Year, len(y.Marks))
+		}
+	}
+}
+
+func TestClient_Archive(t *testing.T) {
+	cli := newFakeClient(t, fakeSite())
+	var buf bytes.Buffer
+	if err := cli.Archive(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	var bundle ArchiveBundle
+	if err := json.Unmarshal(buf.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Version != ArchiveVersion || len(bundle.Years) != 3 || len(bundle.Messages) != 1 {
+		t.Fatalf("bundle - %+v", bundle)
+	}
+	if bundle.Messages[0].Body != "Собрание в пятницу" {
+		t.Errorf("message body - %q", bundle.Messages[0].Body)
+	}
+	y := bundle.Years[1]
+	if y.Year != 2021 || y.Homework[0].Homework != "№ 2021" || y.Marks[0].Grade[0] != 5 {
+		t.Errorf("year - %+v", y)
+	}
+}
@@ -0,0 +1,44 @@
+// Package dnevnik76 cache
+package dnevnik76
+
+import "sync"
+
+// cache of rarely changing data shared by a client
+type cache struct {
+	mu      sync.Mutex
+	periods map[int][]Lperiod
+	infos   map[EduYear]CurrentInfo
+}
+
+func newCache() *cache {
+	return &cache{periods: map[int][]Lperiod{}, infos: map[EduYear]CurrentInfo{}}
+}
+
+func (c *cache) getPeriods(year int) ([]Lperiod, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	periods, ok := c.periods[year]
+	if !ok {
+		return nil, false
+	}
+	return append([]Lperiod(nil), periods...), true
+}
+
+func (c *cache) setPeriods(year int, periods []Lperiod) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.periods[year] = append([]Lperiod(nil), periods...)
+}
+
+func (c *cache) getInfo(year EduYear) (CurrentInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok := c.infos[year]
+	return info, ok
+}
+
+func (c *cache) setInfo(year EduYear, info CurrentInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.infos[year] = info
+}
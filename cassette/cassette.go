@@ -0,0 +1,355 @@
+// Package cassette records HTTP requests and responses of the client into a directory
+// and replays them later, so real pages can be used as offline test fixtures.
+//
+// The login, password and cookies are redacted, but pages are saved as they are:
+// names, marks and messages of the pupil stay in the cassette unless Scrub removes them,
+// so do not publish recorded cassettes as they are.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces secret values in the cassette
+const Redacted = "REDACTED"
+
+// ErrNotRecorded is returned on replay for a request with no matching interaction
+var ErrNotRecorded = errors.New("cassette: request not recorded")
+
+// Mode of the transport
+type Mode int
+
+const (
+	// Replay answers requests from the cassette without network
+	Replay Mode = iota
+	// Record passes requests to the next transport and saves every interaction
+	Record
+)
+
+// Matcher selects the request parts compared on replay
+type Matcher struct {
+	Method bool
+	Host   bool
+	Path   bool
+	Query  bool
+	// Form compares url-encoded bodies field by field
+	Form bool
+	// Ignore query and form fields, redacted fields are never compared
+	Ignore []string
+}
+
+// DefaultMatcher compares method, path, query and form fields except the session CSRF token
+var DefaultMatcher = Matcher{Method: true, Path: true, Query: true, Form: true, Ignore: []string{"csrfmiddlewaretoken"}}
+
+// Interaction is a request with its response, one file of the cassette
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request as recorded, with secrets redacted
+type Request struct {
+	Method string              `json:"method"`
+	URL    string              `json:"url"`
+	Header http.Header         `json:"header,omitempty"`
+	Form   map[string][]string `json:"form,omitempty"`
+	// Body of a request that is not a form
+	Body string `json:"body,omitempty"`
+}
+
+// Response as recorded
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+	// Base64 is set for binary body
+	Base64 bool `json:"base64,omitempty"`
+}
+
+// Transport to record or replay HTTP interactions
+type Transport struct {
+	Mode    Mode
+	Dir     string
+	Matcher Matcher
+	// Redact query and form fields, their values are replaced with Redacted when recording
+	Redact []string
+	// Scrub response bodies and bodies of requests that are not forms before they are saved,
+	// e.g. to replace names, the site and the client still get the bodies as they are
+	Scrub func(body []byte) []byte
+	// Next transport the requests are recorded from
+	Next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	// used interactions on replay
+	used map[*Interaction]bool
+}
+
+// New transport in mode for cassette directory.
+// Record wraps next, http.DefaultTransport when nil, and appends to the cassette creating the directory.
+// Replay loads the directory, next is not used.
+func New(dir string, mode Mode, next http.RoundTripper) (t *Transport, err error) {
+	t = &Transport{Mode: mode, Dir: dir, Matcher: DefaultMatcher, Redact: []string{"username", "password"}, Next: next, used: map[*Interaction]bool{}}
+	if mode == Record {
+		if t.Next == nil {
+			t.Next = http.DefaultTransport
+		}
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			return
+		}
+	}
+	t.interactions, err = Load(dir)
+	return
+}
+
+// Load interactions of cassette directory in recorded order
+func Load(dir string) (interactions []*Interaction, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		i := &Interaction{}
+		if err = json.Unmarshal(data, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		interactions = append(interactions, i)
+	}
+	return
+}
+
+// Interactions recorded or loaded so far
+func (t *Transport) Interactions() []*Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Interaction(nil), t.interactions...)
+}
+
+// RoundTrip to record or replay request
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec, req, err := t.request(req)
+	if err != nil {
+		return nil, err
+	}
+	if t.Mode == Replay {
+		return t.replay(req, rec)
+	}
+	return t.record(req, rec)
+}
+
+func (t *Transport) record(req *http.Request, rec Request) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := &Interaction{Request: rec, Response: Response{StatusCode: resp.StatusCode, Header: redactHeader(resp.Header)}}
+	if t.Scrub != nil {
+		body = t.Scrub(append([]byte(nil), body...))
+	}
+	if utf8.Valid(body) {
+		i.Response.Body = string(body)
+	} else {
+		i.Response.Body, i.Response.Base64 = base64.StdEncoding.EncodeToString(body), true
+	}
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interactions = append(t.interactions, i)
+	name := fmt.Sprintf("%06d-%s-%s.json", len(t.interactions), rec.Method, slug(req.URL.Path))
+	if err = os.WriteFile(filepath.Join(t.Dir, name), data, 0o600); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay the first unused matching interaction, the last matching one when all are used
+func (t *Transport) replay(req *http.Request, rec Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var found *Interaction
+	for _, i := range t.interactions {
+		if !t.match(rec, i.Request) {
+			continue
+		}
+		found = i
+		if !t.used[i] {
+			break
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.URL)
+	}
+	t.used[found] = true
+
+	body := []byte(found.Response.Body)
+	if found.Response.Base64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(found.Response.Body); err != nil {
+			return nil, err
+		}
+	}
+	header := found.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", found.Response.StatusCode, http.StatusText(found.Response.StatusCode)),
+		StatusCode:    found.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// request to record, the body of req is read and passed on with a clone of req
+func (t *Transport) request(req *http.Request) (rec Request, clone *http.Request, err error) {
+	u := *req.URL
+	u.RawQuery = t.redact(u.Query()).Encode()
+	rec = Request{Method: req.Method, URL: u.String(), Header: redactHeader(req.Header)}
+	clone = req
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return
+	}
+	clone = req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, perr := url.ParseQuery(string(body))
+		if perr == nil {
+			rec.Form = t.redact(form)
+			return
+		}
+	}
+	rec.Body = string(body)
+	if t.Scrub != nil {
+		rec.Body = string(t.Scrub(append([]byte(nil), body...)))
+	}
+	return
+}
+
+func (t *Transport) redact(values url.Values) url.Values {
+	for _, f := range t.Redact {
+		if _, ok := values[f]; ok {
+			values[f] = []string{Redacted}
+		}
+	}
+	return values
+}
+
+// match request with recorded one
+func (t *Transport) match(req, rec Request) bool {
+	m := t.Matcher
+	if m.Method && req.Method != rec.Method {
+		return false
+	}
+	u1, err1 := url.Parse(req.URL)
+	u2, err2 := url.Parse(rec.URL)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if m.Host && u1.Host != u2.Host {
+		return false
+	}
+	if m.Path && strings.TrimSuffix(u1.Path, "/") != strings.TrimSuffix(u2.Path, "/") {
+		return false
+	}
+	if m.Query && !t.sameValues(u1.Query(), u2.Query()) {
+		return false
+	}
+	if m.Form && (!t.sameValues(req.Form, rec.Form) || req.Body != rec.Body) {
+		return false
+	}
+	return true
+}
+
+func (t *Transport) sameValues(a, b url.Values) bool {
+	skip := map[string]bool{}
+	for _, f := range append(t.Matcher.Ignore, t.Redact...) {
+		skip[f] = true
+	}
+	clean := func(v url.Values) string {
+		c := url.Values{}
+		for k, vs := range v {
+			if !skip[k] {
+				c[k] = vs
+			}
+		}
+		return c.Encode()
+	}
+	return clean(a) == clean(b)
+}
+
+// redactHeader to keep cookie names and attributes without session values
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for i, v := range h["Cookie"] {
+		h["Cookie"][i] = reCookieValue.ReplaceAllString(v, "${1}${2}="+Redacted)
+	}
+	for i, v := range h["Set-Cookie"] {
+		pair, attrs, _ := strings.Cut(v, ";")
+		if name, _, ok := strings.Cut(pair, "="); ok {
+			h["Set-Cookie"][i] = name + "=" + Redacted
+			if attrs != "" {
+				h["Set-Cookie"][i] += ";" + attrs
+			}
+		}
+	}
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", Redacted)
+	}
+	return h
+}
+
+var (
+	reCookieValue = regexp.MustCompile(`(^|;\s*)([^=;\s]+)=[^;]*`)
+	reSlug        = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+func slug(path string) string {
+	s := strings.Trim(reSlug.ReplaceAllString(path, "-"), "-")
+	if s == "" {
+		return "root"
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, hc *http.Client, url string) string {
+	t.Helper()
+	resp, err := hc.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestTransport(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			http.SetCookie(w, &http.Cookie{Name: "sessionid", Value: "s3cr3t", Path: "/"})
+			fmt.Fprintf(w, "welcome %s", r.PostForm.Get("username"))
+			return
+		}
+		fmt.Fprintf(w, "%s?%s #%d", r.URL.Path, r.URL.RawQuery, hits)
+	}))
+	dir := filepath.Join(t.TempDir(), "cassette")
+
+	rec, err := New(dir, Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc := &http.Client{Transport: rec}
+	login := func(hc *http.Client, password, token string) string {
+		resp, err := hc.PostForm(srv.URL+"/accounts/login/", url.Values{
+			"username": {"08331111@760215"}, "password": {password}, "csrfmiddlewaretoken": {token}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	if body := login(hc, "123456", "t1"); body != "welcome 08331111@760215" {
+		t.Fatalf("record login - %q", body)
+	}
+	get(t, hc, srv.URL+"/marks/current/q1/note/?page=1")
+	get(t, hc, srv.URL+"/marks/current/q1/note/?page=2")
+	get(t, hc, srv.URL+"/marks/current/q1/note/?page=1")
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 4 || filepath.Base(files[1]) != "000002-GET-marks-current-q1-note.json" {
+		t.Fatalf("files - %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "123456") || strings.Contains(string(data), "s3cr3t") || !strings.Contains(string(data), "sessionid=REDACTED; Path=/") ||
+		rec.Interactions()[0].Request.Form["username"][0] != Redacted {
+		t.Errorf("not redacted - %s", data)
+	}
+
+	srv.Close()
+	rep, err := New(dir, Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc = &http.Client{Transport: rep}
+	if body := login(hc, "other", "t2"); body != "welcome 08331111@760215" {
+		t.Errorf("replay login - %q", body)
+	}
+	for _, want := range []string{"#2", "#4", "#4"} {
+		if body := get(t, hc, srv.URL+"/marks/current/q1/note/?page=1"); !strings.HasSuffix(body, want) {
+			t.Errorf("replay %q, want %s", body, want)
+		}
+	}
+	if body := get(t, hc, srv.URL+"/marks/current/q1/note/?page=2"); !strings.HasSuffix(body, "#3") {
+		t.Errorf("replay page 2 - %q", body)
+	}
+	if _, err = hc.Get(srv.URL + "/messages/input"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("not recorded - %v", err)
+	}
+
+	rep.Matcher.Query = false
+	if _, err = hc.Get(srv.URL + "/marks/current/q1/note/?page=9"); err != nil {
+		t.Errorf("query ignored - %v", err)
+	}
+	if _, err = hc.Get(srv.URL + "/accounts/login/"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("method compared - %v", err)
+	}
+	rep.Matcher.Method, rep.Matcher.Form = false, false
+	if _, err = hc.Get(srv.URL + "/accounts/login/"); err != nil {
+		t.Errorf("method ignored - %v", err)
+	}
+}
+
+func TestTransport_Scrub(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "Иванов Петр: %s", body)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	scrub := func(body []byte) []byte {
+		return []byte(strings.ReplaceAll(string(body), "Иванов", "Фамилия"))
+	}
+
+	rec, err := New(dir, Record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Scrub = scrub
+	resp, err := (&http.Client{Transport: rec}).Post(srv.URL+"/messages/new/", "text/plain", strings.NewReader("Иванову"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "Иванов Петр: Иванову" {
+		t.Errorf("scrubbed before the client - %q", body)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	data, _ := os.ReadFile(files[0])
+	if strings.Contains(string(data), "Иванов") || !strings.Contains(string(data), "Фамилия Петр: Фамилияу") {
+		t.Errorf("not scrubbed - %s", data)
+	}
+
+	rep, err := New(dir, Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Scrub = scrub
+	resp, err = (&http.Client{Transport: rep}).Post(srv.URL+"/messages/new/", "text/plain", strings.NewReader("Иванову"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
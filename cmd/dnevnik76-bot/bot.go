@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/watch"
+)
+
+// Diary is the part of *dnevnik76.Client used by the bot
+type Diary = dnevnik76.Diary
+
+// Connect logs into diary with credentials
+type Connect func(c Credentials) (Diary, error)
+
+// Login to dnevnik76.ru with credentials
+func Login(c Credentials) (Diary, error) {
+	cli := dnevnik76.NewClient(c.Login, c.Password, c.RegionID, c.SchoolID, nil)
+	if err := cli.Login(); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
+
+const help = `Команды:
+/login логин пароль регион школа - подключить дневник
+/logout - отключить дневник
+/marks - оценки за месяц
+/homework - домашние задания
+/final - итоговые оценки
+/messages - сообщения
+/teachers - учителя
+/subscribe - уведомлять о новых оценках, сообщениях и заданиях
+/unsubscribe - не уведомлять`
+
+// Bot answers chat commands with diary data
+type Bot struct {
+	tg      *Telegram
+	store   *Store
+	connect Connect
+	// watcher configures subscription watchers
+	watcher func(w *watch.Watcher)
+
+	mu       sync.Mutex
+	diaries  map[int64]Diary
+	watchers map[int64]context.CancelFunc
+}
+
+// NewBot with telegram client, credentials store and diary connector
+func NewBot(tg *Telegram, store *Store, connect Connect) *Bot {
+	return &Bot{
+		tg:       tg,
+		store:    store,
+		connect:  connect,
+		diaries:  map[int64]Diary{},
+		watchers: map[int64]context.CancelFunc{},
+	}
+}
+
+// Run to poll updates until ctx is done
+func (b *Bot) Run(ctx context.Context) error {
+	for _, id := range b.store.Chats() {
+		if c, ok, err := b.store.Get(id); err == nil && ok && c.Subscribed {
+			if err = b.subscribe(ctx, id); err != nil {
+				log.Printf("chat %d: subscribe: %s", id, err)
+			}
+		}
+	}
+
+	var offset int64
+	for {
+		updates, err := b.tg.GetUpdates(ctx, offset, 30*time.Second)
+		if ctx.Err() != nil {
+			b.stopAll()
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("getUpdates: %s", err)
+			select {
+			case <-ctx.Done():
+				b.stopAll()
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				b.Handle(ctx, *u.Message)
+			}
+		}
+	}
+}
+
+// Handle chat message
+func (b *Bot) Handle(ctx context.Context, m Message) {
+	reply, err := b.command(ctx, m)
+	if err != nil {
+		reply = "Ошибка: " + err.Error()
+	}
+	if reply == "" {
+		return
+	}
+	if err = b.tg.SendMessage(ctx, m.Chat.ID, reply); err != nil {
+		log.Printf("chat %d: sendMessage: %s", m.Chat.ID, err)
+	}
+}
+
+func (b *Bot) command(ctx context.Context, m Message) (string, error) {
+	args := strings.Fields(m.Text)
+	if len(args) == 0 {
+		return "", nil
+	}
+	chatID := m.Chat.ID
+	cmd := strings.SplitN(args[0], "@", 2)[0]
+	switch cmd {
+	case "/start", "/help":
+		return help, nil
+	case "/login":
+		// password should not stay in chat history
+		b.tg.DeleteMessage(ctx, chatID, m.MessageID)
+		return b.login(chatID, args[1:])
+	case "/logout":
+		b.unsubscribe(chatID)
+		b.mu.Lock()
+		delete(b.diaries, chatID)
+		b.mu.Unlock()
+		return "Дневник отключён", b.store.Delete(chatID)
+	case "/subscribe", "/unsubscribe":
+		c, ok, err := b.store.Get(chatID)
+		if err != nil || !ok {
+			return "Сначала выполните /login", err
+		}
+		c.Subscribed = cmd == "/subscribe"
+		if err = b.store.Put(chatID, c); err != nil {
+			return "", err
+		}
+		if !c.Subscribed {
+			b.unsubscribe(chatID)
+			return "Уведомления отключены", nil
+		}
+		return "Уведомления включены", b.subscribe(ctx, chatID)
+	}
+
+	d, err := b.diary(chatID)
+	if err != nil {
+		return "", err
+	}
+	if d == nil {
+		return "Сначала выполните /login", nil
+	}
+	switch cmd {
+	case "/marks":
+		marks, err := d.GetMarksCurrent()
+		return formatMarks(marks), err
+	case "/homework":
+		hws, err := d.GetHomework()
+		return formatHomework(hws), err
+	case "/final":
+		marks, err := d.GetMarksFinal()
+		return formatFinal(marks), err
+	case "/messages":
+		messages, err := d.GetMessages()
+		return formatMessages(messages), err
+	case "/teachers":
+		teachers, err := d.GetTeachers()
+		return formatTeachers(teachers), err
+	}
+	return "Неизвестная команда\n\n" + help, nil
+}
+
+func (b *Bot) login(chatID int64, args []string) (string, error) {
+	if len(args) != 4 {
+		return "Формат: /login логин пароль регион школа", nil
+	}
+	c := Credentials{Login: args[0], Password: args[1]}
+	var err error
+	if c.RegionID, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+		return "Регион должен быть числом", nil
+	}
+	if c.SchoolID, err = strconv.ParseInt(args[3], 10, 64); err != nil {
+		return "Школа должна быть числом", nil
+	}
+	d, err := b.connect(c)
+	if err != nil {
+		return "", err
+	}
+	if err = b.store.Put(chatID, c); err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	b.diaries[chatID] = dnevnik76.NewLocked(d)
+	b.mu.Unlock()
+	return "Дневник подключён", nil
+}
+
+// diary of chat, connected on first use
+func (b *Bot) diary(chatID int64) (Diary, error) {
+	b.mu.Lock()
+	d, ok := b.diaries[chatID]
+	b.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+	c, ok, err := b.store.Get(chatID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if d, err = b.connect(c); err != nil {
+		return nil, err
+	}
+	d = dnevnik76.NewLocked(d)
+	b.mu.Lock()
+	b.diaries[chatID] = d
+	b.mu.Unlock()
+	return d, nil
+}
+
+func (b *Bot) subscribe(ctx context.Context, chatID int64) error {
+	d, err := b.diary(chatID)
+	if err != nil || d == nil {
+		return err
+	}
+	b.unsubscribe(chatID)
+
+	w := watch.New(d)
+	w.OnError = func(err error) { log.Printf("chat %d: watch: %s", chatID, err) }
+	if b.watcher != nil {
+		b.watcher(w)
+	}
+	wctx, cancel := context.WithCancel(ctx)
+	b.mu.Lock()
+	b.watchers[chatID] = cancel
+	b.mu.Unlock()
+	go w.Run(wctx, func(e watch.Event) {
+		if err := b.tg.SendMessage(wctx, chatID, formatEvent(e)); err != nil {
+			log.Printf("chat %d: sendMessage: %s", chatID, err)
+		}
+	})
+	return nil
+}
+
+func (b *Bot) unsubscribe(chatID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cancel, ok := b.watchers[chatID]; ok {
+		cancel()
+		delete(b.watchers, chatID)
+	}
+}
+
+func (b *Bot) stopAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, cancel := range b.watchers {
+		cancel()
+		delete(b.watchers, id)
+	}
+}
+
+func grades(g []int8) string {
+	s := make([]string, len(g))
+	for i, v := range g {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, " ")
+}
+
+func formatMarks(marks []dnevnik76.Mark) string {
+	sort.Stable(dnevnik76.MarksByDate(marks))
+	var sb strings.Builder
+	for _, m := range marks {
+		if len(m.Grade) > 0 {
+			fmt.Fprintf(&sb, "%s %s: %s\n", m.Date.Format("02.01"), strings.TrimSpace(m.CourseName), grades(m.Grade))
+		}
+	}
+	if sb.Len() == 0 {
+		return "Оценок нет"
+	}
+	return sb.String()
+}
+
+func formatFinal(marks []dnevnik76.Mark) string {
+	var courses []string
+	rows := map[string][]string{}
+	for _, m := range marks {
+		if _, ok := rows[m.CourseName]; !ok {
+			courses = append(courses, m.CourseName)
+		}
+		p := fmt.Sprintf("%d ч.", m.Quarter)
+		if m.Annual {
+			p = "год"
+		}
+		rows[m.CourseName] = append(rows[m.CourseName], fmt.Sprintf("%s %s", p, grades(m.Grade)))
+	}
+	if len(courses) == 0 {
+		return "Итоговых оценок нет"
+	}
+	var sb strings.Builder
+	for _, c := range courses {
+		fmt.Fprintf(&sb, "%s: %s\n", c, strings.Join(rows[c], ", "))
+	}
+	return sb.String()
+}
+
+func formatHomework(hws []dnevnik76.Homework) string {
+	var sb strings.Builder
+	for _, h := range hws {
+		fmt.Fprintf(&sb, "%s %s: %s\n", h.Date.Format("02.01"), h.CourseName, h.Homework)
+	}
+	if sb.Len() == 0 {
+		return "Заданий нет"
+	}
+	return sb.String()
+}
+
+func formatMessages(messages []dnevnik76.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		mark := ""
+		if m.IsUnread {
+			mark = "● "
+		}
+		fmt.Fprintf(&sb, "%s%s %s: %s\n", mark, m.Date.Format("02.01 15:04"), strings.TrimSpace(m.From), m.Subject)
+	}
+	if sb.Len() == 0 {
+		return "Сообщений нет"
+	}
+	return sb.String()
+}
+
+func formatTeachers(teachers []dnevnik76.Teacher) string {
+	var sb strings.Builder
+	for _, t := range teachers {
+		fmt.Fprintf(&sb, "%s - %s\n", t.CourseName, strings.TrimSpace(t.FullName))
+	}
+	if sb.Len() == 0 {
+		return "Учителей нет"
+	}
+	return sb.String()
+}
+
+func formatEvent(e watch.Event) string {
+	switch e.Type {
+	case watch.NewMark:
+		return fmt.Sprintf("Новая оценка: %s %s - %s", e.Mark.Date.Format("02.01"), strings.TrimSpace(e.Mark.CourseName), grades(e.Mark.Grade))
+	case watch.NewMessage:
+		return fmt.Sprintf("Новое сообщение от %s: %s", strings.TrimSpace(e.Message.From), e.Message.Subject)
+	case watch.NewHomework:
+		return fmt.Sprintf("Новое задание: %s %s - %s", e.Homework.Date.Format("02.01"), e.Homework.CourseName, e.Homework.Homework)
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/watch"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+type fakeDiary struct {
+	mu    sync.Mutex
+	marks []dnevnik76.Mark
+	// busy and overlapped detect concurrent calls
+	busy       int32
+	overlapped int32
+}
+
+// enter a call, the returned func leaves it
+func (d *fakeDiary) enter() func() {
+	if !atomic.CompareAndSwapInt32(&d.busy, 0, 1) {
+		atomic.StoreInt32(&d.overlapped, 1)
+	}
+	time.Sleep(time.Millisecond)
+	return func() { atomic.StoreInt32(&d.busy, 0) }
+}
+
+func (d *fakeDiary) GetMarksCurrent() ([]dnevnik76.Mark, error) {
+	defer d.enter()()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]dnevnik76.Mark(nil), d.marks...), nil
+}
+func (d *fakeDiary) GetMarksFinal() ([]dnevnik76.Mark, error) {
+	defer d.enter()()
+	return []dnevnik76.Mark{{CourseName: "Физика", Quarter: 1, Grade: []int8{5}}, {CourseName: "Физика", Annual: true, Grade: []int8{5}}}, nil
+}
+func (d *fakeDiary) GetHomework() ([]dnevnik76.Homework, error) { defer d.enter()(); return nil, nil }
+func (d *fakeDiary) GetMessagesCount() (int, int, error)        { defer d.enter()(); return 0, 0, nil }
+func (d *fakeDiary) GetMessages() ([]dnevnik76.Message, error)  { defer d.enter()(); return nil, nil }
+func (d *fakeDiary) GetTeachers() ([]dnevnik76.Teacher, error) {
+	defer d.enter()()
+	return []dnevnik76.Teacher{{CourseName: "Физика", FullName: "Иванова М. П."}}, nil
+}
+
+// fakeTelegram records calls of Bot API methods
+type fakeTelegram struct {
+	mu    sync.Mutex
+	calls []map[string]interface{}
+	sent  chan string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&params)
+	params["method"] = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	f.mu.Lock()
+	f.calls = append(f.calls, params)
+	f.mu.Unlock()
+	if params["method"] == "sendMessage" {
+		f.sent <- params["text"].(string)
+	}
+	w.Write([]byte(`{"ok":true,"result":true}`))
+}
+
+func newTestBot(t *testing.T) (*Bot, *fakeTelegram, *fakeDiary, string) {
+	ft := &fakeTelegram{sent: make(chan string, 10)}
+	srv := httptest.NewServer(ft)
+	t.Cleanup(srv.Close)
+	path := filepath.Join(t.TempDir(), "store.json")
+	store, err := OpenStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diary := &fakeDiary{marks: []dnevnik76.Mark{{CourseName: "Физика", Date: time.Date(2022, 9, 5, 0, 0, 0, 0, time.Local), Grade: []int8{4, 5}}}}
+	connect := func(c Credentials) (Diary, error) { return diary, nil }
+	tg := &Telegram{API: srv.URL, Token: "123:abc", HTTP: srv.Client()}
+	return NewBot(tg, store, connect), ft, diary, path
+}
+
+func send(ctx context.Context, b *Bot, ft *fakeTelegram, text string) string {
+	b.Handle(ctx, Message{MessageID: 42, Chat: Chat{ID: 1}, Text: text})
+	select {
+	case s := <-ft.sent:
+		return s
+	case <-time.After(2 * time.Second):
+		return ""
+	}
+}
+
+func TestBot_Commands(t *testing.T) {
+	b, ft, _, path := newTestBot(t)
+	ctx := context.Background()
+
+	if r := send(ctx, b, ft, "/marks"); !strings.Contains(r, "/login") {
+		t.Errorf("marks before login - %q", r)
+	}
+	if r := send(ctx, b, ft, "/login 08331111 secret 76000001000 760215"); r != "Дневник подключён" {
+		t.Errorf("login - %q", r)
+	}
+	if ft.calls[1]["method"] != "deleteMessage" {
+		t.Errorf("login message was not deleted: %v", ft.calls[1])
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") || strings.Contains(string(data), "08331111") {
+		t.Errorf("credentials stored in plain text: %s", data)
+	}
+
+	cases := map[string]string{
+		"/marks":    "05.09 Физика: 4 5\n",
+		"/final":    "Физика: 1 ч. 5, год 5\n",
+		"/teachers": "Физика - Иванова М. П.\n",
+		"/messages": "Сообщений нет",
+	}
+	for cmd, want := range cases {
+		if r := send(ctx, b, ft, cmd); r != want {
+			t.Errorf("%s - %q, want %q", cmd, r, want)
+		}
+	}
+
+	reopened, _ := OpenStore(path, key)
+	c, ok, err := reopened.Get(1)
+	if err != nil || !ok || c.Password != "secret" || c.SchoolID != 760215 {
+		t.Errorf("stored credentials - %+v, %t, %v", c, ok, err)
+	}
+	if _, _, err = (&Store{path: path, aead: mustGCM(t, []byte("fedcba9876543210fedcba9876543210")), chats: reopened.chats}).Get(1); err == nil {
+		t.Error("credentials opened with another key")
+	}
+}
+
+func TestBot_Subscribe(t *testing.T) {
+	b, ft, diary, _ := newTestBot(t)
+	b.watcher = func(w *watch.Watcher) {
+		w.MessagesInterval, w.MarksInterval, w.HomeworkInterval = 5*time.Millisecond, 5*time.Millisecond, 5*time.Millisecond
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	send(ctx, b, ft, "/login 08331111 secret 76000001000 760215")
+	if r := send(ctx, b, ft, "/subscribe"); r != "Уведомления включены" {
+		t.Fatalf("subscribe - %q", r)
+	}
+	for i := 0; i < 5; i++ {
+		send(ctx, b, ft, "/final")
+	}
+	time.Sleep(30 * time.Millisecond)
+	diary.mu.Lock()
+	diary.marks = append(diary.marks, dnevnik76.Mark{CourseName: "Химия", Date: time.Date(2022, 9, 6, 0, 0, 0, 0, time.Local), Grade: []int8{5}})
+	diary.mu.Unlock()
+
+	select {
+	case s := <-ft.sent:
+		if s != "Новая оценка: 06.09 Химия - 5" {
+			t.Errorf("notification - %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification")
+	}
+	if r := send(ctx, b, ft, "/unsubscribe"); r != "Уведомления отключены" {
+		t.Errorf("unsubscribe - %q", r)
+	}
+	if atomic.LoadInt32(&diary.overlapped) != 0 {
+		t.Error("diary called concurrently by commands and watcher")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	parts := split(strings.Repeat("строка\n", 10), 20)
+	if len(parts) != 5 || parts[0] != "строка\nстрока\n" {
+		t.Errorf("parts - %q", parts)
+	}
+}
+
+func mustGCM(t *testing.T, key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestTelegram_ErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	tg := &Telegram{API: srv.URL, Token: "123:secret", HTTP: srv.Client()}
+	err := tg.SendMessage(context.Background(), 1, "text")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("error - %v", err)
+	}
+}
+
+func TestBot_RunStopsWhileRetrying(t *testing.T) {
+	b, _, _, _ := newTestBot(t)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	b.tg = &Telegram{API: srv.URL, Token: "123:abc", HTTP: srv.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("run - %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("run waits out the retry delay after cancel")
+	}
+}
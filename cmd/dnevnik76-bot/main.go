@@ -0,0 +1,43 @@
+// Command dnevnik76-bot is a Telegram bot for dnevnik76.ru diary
+//
+// Usage:
+//
+//	DNEVNIK76_BOT_TOKEN=... DNEVNIK76_BOT_KEY=<64 hex digits> dnevnik76-bot [-api URL] [-store FILE]
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	api := flag.String("api", DefaultAPI, "Telegram Bot API base URL")
+	storePath := flag.String("store", "dnevnik76-bot.json", "encrypted credentials store")
+	flag.Parse()
+
+	token := os.Getenv("DNEVNIK76_BOT_TOKEN")
+	if token == "" {
+		log.Fatal("DNEVNIK76_BOT_TOKEN is not set")
+	}
+	key, err := hex.DecodeString(os.Getenv("DNEVNIK76_BOT_KEY"))
+	if err != nil || len(key) != 32 {
+		log.Fatal("DNEVNIK76_BOT_KEY must be 32 bytes in hex")
+	}
+	store, err := OpenStore(*storePath, key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tg := &Telegram{API: *api, Token: token, HTTP: &http.Client{Timeout: time.Minute}}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err = NewBot(tg, store, Login).Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
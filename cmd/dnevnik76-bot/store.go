@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Credentials of diary account linked to chat
+type Credentials struct {
+	Login      string `json:"login"`
+	Password   string `json:"password"`
+	RegionID   int64  `json:"regionId"`
+	SchoolID   int64  `json:"schoolId"`
+	Subscribed bool   `json:"subscribed"`
+}
+
+// Store keeps per-chat credentials encrypted with AES-GCM in a JSON file
+type Store struct {
+	path  string
+	aead  cipher.AEAD
+	mu    sync.Mutex
+	chats map[string]string
+}
+
+// OpenStore with 32 bytes key
+func OpenStore(path string, key []byte) (*Store, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, aead: aead, chats: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.chats); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get credentials of chat
+func (s *Store) Get(chatID int64) (c Credentials, ok bool, err error) {
+	s.mu.Lock()
+	sealed, ok := s.chats[chatKey(chatID)]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return
+	}
+	ns := s.aead.NonceSize()
+	if len(raw) < ns {
+		return c, false, errors.New("store: sealed credentials too short")
+	}
+	plain, err := s.aead.Open(nil, raw[:ns], raw[ns:], []byte(chatKey(chatID)))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(plain, &c)
+	return
+}
+
+// Put credentials of chat
+func (s *Store) Put(chatID int64, c Credentials) error {
+	plain, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plain, []byte(chatKey(chatID)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chatKey(chatID)] = base64.StdEncoding.EncodeToString(sealed)
+	return s.save()
+}
+
+// Delete credentials of chat
+func (s *Store) Delete(chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chats, chatKey(chatID))
+	return s.save()
+}
+
+// Chats with stored credentials
+func (s *Store) Chats() (ids []int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.chats {
+		if id, err := strconv.ParseInt(k, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+func (s *Store) save() error {
+	data, err := json.Marshal(s.chats)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultAPI is the Telegram Bot API base URL
+const DefaultAPI = "https://api.telegram.org"
+
+// Telegram Bot API client with just what the bot needs
+type Telegram struct {
+	API   string
+	Token string
+	HTTP  *http.Client
+}
+
+// Update from getUpdates
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+// Message from chat
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	Chat      Chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+// Chat of message
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+func (tg *Telegram) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/%s", tg.API, url.PathEscape(tg.Token), method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := tg.HTTP.Do(req)
+	if err != nil {
+		// the URL has the token in it, keep it out of the logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("%s: %w", method, uerr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r apiResponse
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+// GetUpdates with long polling
+func (tg *Telegram) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) (updates []Update, err error) {
+	params := map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}
+	err = tg.call(ctx, "getUpdates", params, &updates)
+	return
+}
+
+// SendMessage to chat, long texts are split into several messages
+func (tg *Telegram) SendMessage(ctx context.Context, chatID int64, text string) error {
+	for _, part := range split(text, 4096) {
+		params := map[string]interface{}{"chat_id": chatID, "text": part}
+		if err := tg.call(ctx, "sendMessage", params, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMessage from chat
+func (tg *Telegram) DeleteMessage(ctx context.Context, chatID, messageID int64) error {
+	params := map[string]interface{}{"chat_id": chatID, "message_id": messageID}
+	return tg.call(ctx, "deleteMessage", params, nil)
+}
+
+// split text by lines into parts of at most n runes
+func split(text string, n int) (parts []string) {
+	var cur []rune
+	for _, line := range bytes.SplitAfter([]byte(text), []byte("\n")) {
+		r := []rune(string(line))
+		if len(cur)+len(r) > n && len(cur) > 0 {
+			parts = append(parts, string(cur))
+			cur = nil
+		}
+		for len(r) > n {
+			parts = append(parts, string(r[:n]))
+			r = r[n:]
+		}
+		cur = append(cur, r...)
+	}
+	if len(cur) > 0 || len(parts) == 0 {
+		parts = append(parts, string(cur))
+	}
+	return
+}
+
+func chatKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,117 @@
+// Command dnevnik76 is a command line client for dnevnik76.ru diary
+//
+// Usage:
+//
+//	dnevnik76 [-config FILE] [-selectors FILE] [-record DIR | -replay DIR] diagnose
+//
+// The config file has the same fields as config_test.json: login, password, region_id and school_id.
+// The selectors file is a YAML or JSON selector profile overriding the default site layout.
+// With -record every page fetched is saved to a cassette directory with the login, password
+// and cookies redacted, -replay answers requests from it without network. The pages are saved
+// as they are with names, marks and messages, do not publish a recorded cassette.
+//
+// diagnose checks that every page selector the library depends on still matches
+// and prints the report as JSON, the exit status is 1 when the site layout changed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/cassette"
+	"github.com/bvp/dnevnik76-api/parse"
+)
+
+// Config of the diary account
+type Config struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	RegionID int64  `json:"region_id"`
+	SchoolID int64  `json:"school_id"`
+}
+
+func main() {
+	configPath := flag.String("config", "dnevnik76.json", "account config file")
+	selectorsPath := flag.String("selectors", "", "selector profile file, YAML or JSON")
+	recordDir := flag.String("record", "", "cassette directory to record requests to")
+	replayDir := flag.String("replay", "", "cassette directory to replay requests from")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config FILE] [-selectors FILE] [-record DIR | -replay DIR] diagnose\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || flag.Arg(0) != "diagnose" || *recordDir != "" && *replayDir != "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cli := dnevnik76.NewClient(cfg.Login, cfg.Password, cfg.RegionID, cfg.SchoolID, nil)
+	if *selectorsPath != "" {
+		sel, err := parse.LoadProfileFile(*selectorsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cli.Selectors = &sel
+	}
+	if *recordDir != "" || *replayDir != "" {
+		mode, dir := cassette.Record, *recordDir
+		if *replayDir != "" {
+			mode, dir = cassette.Replay, *replayDir
+		}
+		rt, err := cassette.New(dir, mode, cli.Transport())
+		if err != nil {
+			log.Fatal(err)
+		}
+		cli.SetTransport(rt)
+	}
+	if err = cli.Login(); err != nil {
+		log.Fatal(err)
+	}
+	ok, err := diagnose(ctx, cli, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// LoadConfig from JSON file
+func LoadConfig(path string) (cfg Config, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Login == "" || cfg.SchoolID == 0 {
+		err = fmt.Errorf("%s: login and school_id are required", path)
+	}
+	return
+}
+
+// diagnose to write the site layout report
+func diagnose(ctx context.Context, cli *dnevnik76.Client, w io.Writer) (ok bool, err error) {
+	d, err := cli.Diagnose(ctx)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return d.OK, enc.Encode(d)
+}
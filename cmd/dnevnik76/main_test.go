@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("../../config_test.json.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != (Config{Login: "08331111", Password: "123456", RegionID: 76000001000, SchoolID: 760215}) {
+		t.Errorf("config - %+v", cfg)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"login": "08331111"}`), 0o600)
+	if _, err = LoadConfig(path); err == nil {
+		t.Error("school_id is required")
+	}
+}
@@ -0,0 +1,246 @@
+// Package dnevnik76 site layout diagnosis
+package dnevnik76
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/bvp/dnevnik76-api/parse"
+)
+
+// Diagnosis of the site layout the parsers depend on
+type Diagnosis struct {
+	CheckedAt time.Time   `json:"checkedAt"`
+	OK        bool        `json:"ok"`
+	Pages     []PageCheck `json:"pages"`
+}
+
+// PageCheck of one page
+type PageCheck struct {
+	Page   string `json:"page"`
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	// Error of fetching the page, selectors are not checked then
+	Error     string          `json:"error,omitempty"`
+	OK        bool            `json:"ok"`
+	Selectors []SelectorCheck `json:"selectors"`
+}
+
+// SelectorCheck of one CSS selector or pattern of the selector profile
+type SelectorCheck struct {
+	// Field of the profile, e.g. note.rows
+	Field    string `json:"field"`
+	Selector string `json:"selector"`
+	// Pattern matched against the elements of Selector, only the matching ones are counted
+	Pattern string `json:"pattern,omitempty"`
+	// Expected is the least number of matches, 0 for elements that may be missing, e.g. rows of an empty list
+	Expected int  `json:"expected"`
+	Actual   int  `json:"actual"`
+	OK       bool `json:"ok"`
+}
+
+// Failed checks of the diagnosis, pages with failed selectors only
+func (d Diagnosis) Failed() (pages []PageCheck) {
+	for _, p := range d.Pages {
+		if p.OK {
+			continue
+		}
+		failed := p
+		failed.Selectors = nil
+		for _, s := range p.Selectors {
+			if !s.OK {
+				failed.Selectors = append(failed.Selectors, s)
+			}
+		}
+		pages = append(pages, failed)
+	}
+	return
+}
+
+// expect is a selector with the least number of matches
+type expect struct {
+	field    string
+	selector string
+	// pattern the attr, or the text when empty, of the selected elements has to match
+	pattern string
+	attr    string
+	min     int
+}
+
+// sitePage the parsers read
+type sitePage struct {
+	name string
+	url  func(cli *Client) string
+	// anonymous pages are fetched without session
+	anonymous bool
+	// sections of the selector profile read from the page
+	sections []string
+}
+
+var sitePages = []sitePage{
+	{name: "login", url: static(urlLogin), anonymous: true, sections: []string{"login"}},
+	{name: "regions", url: static(urlAjax + "/kladr/?login=true"), anonymous: true, sections: []string{"options"}},
+	{name: "schools", url: func(cli *Client) string {
+		return fmt.Sprintf("%s/school/%d/?login=true", urlAjax, cli.CurrentInfo.RegionID)
+	}, anonymous: true, sections: []string{"options"}},
+	{name: "homework", url: static(urlHomework), sections: []string{"info", "homework"}},
+	{name: "courses", url: func(cli *Client) string {
+		return fmt.Sprintf("%s/subj/%d", urlAjax, cli.CurrentInfo.ClassID)
+	}, sections: []string{"options"}},
+	{name: "marks note", url: static(urlMarksCurrent + "note/"), sections: []string{"periods", "note"}},
+	{name: "marks list", url: static(urlMarksCurrent + "list/"), sections: []string{"list"}},
+	{name: "marks final", url: static(urlMarksFinal), sections: []string{"final"}},
+	{name: "messages", url: static(urlMessages), sections: []string{"messages"}},
+	{name: "message", sections: []string{"messages"}},
+	{name: "teachers", url: static(urlTeachers), sections: []string{"teachers"}},
+}
+
+// expects of the page generated from the profile fields of its sections
+func (p sitePage) expects(profile *parse.SelectorProfile) (expects []expect) {
+	fields := profile.Fields()
+	byName := make(map[string]parse.ProfileField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+	for _, f := range fields {
+		section, _, _ := strings.Cut(f.Name, ".")
+		if !contains(p.sections, section) || f.Tag.Get("page") != "" && !contains(strings.Split(f.Tag.Get("page"), ","), p.name) {
+			continue
+		}
+		e := expect{field: f.Name}
+		target := f
+		if f.Pattern {
+			e.pattern, e.attr = f.Value, f.Tag.Get("attr")
+			target = byName[section+"."+f.Tag.Get("of")]
+		}
+		var optional bool
+		e.selector, optional = selectorPath(byName, section, target)
+		if !optional {
+			e.min = 1
+		}
+		expects = append(expects, e)
+	}
+	return
+}
+
+// selectorPath of field within the ones it is relative to, optional when any of them may be missing
+func selectorPath(fields map[string]parse.ProfileField, section string, f parse.ProfileField) (selector string, optional bool) {
+	selector, optional = f.Value, f.Tag.Get("optional") == "true"
+	for in := f.Tag.Get("in"); in != ""; in = f.Tag.Get("in") {
+		f = fields[section+"."+in]
+		selector = f.Value + " " + selector
+		optional = optional || f.Tag.Get("optional") == "true"
+	}
+	return
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func static(url string) func(*Client) string {
+	return func(*Client) string { return url }
+}
+
+// Diagnose to fetch every page the parsers depend on and count matches of the client selectors,
+// so a site layout change shows up before marks silently drop to zero.
+// The client has to be logged in, the message page is checked on the first inbox message.
+func (cli *Client) Diagnose(ctx context.Context) (d Diagnosis, err error) {
+	d.CheckedAt = time.Now()
+	d.OK = true
+	var messageURL string
+	for _, p := range sitePages {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var url string
+		switch {
+		case p.url != nil:
+			url = p.url(cli)
+		case messageURL != "":
+			url = messageURL
+		default:
+			// no message to look at
+			continue
+		}
+		pc, doc := cli.checkPage(ctx, p, url)
+		if p.name == "messages" && doc != nil {
+			sel := cli.selectors().Messages
+			if id, ok := doc.Find(sel.Rows + " " + sel.ID).First().Attr("value"); ok {
+				messageURL = fmt.Sprintf("%s/%s/", urlMessages, id)
+			}
+		}
+		d.OK = d.OK && pc.OK
+		d.Pages = append(d.Pages, pc)
+	}
+	return
+}
+
+func (cli *Client) checkPage(ctx context.Context, p sitePage, url string) (pc PageCheck, doc *goquery.Document) {
+	pc.Page, pc.URL = p.name, url
+	hc := cli.http
+	if p.anonymous {
+		hc = &http.Client{Transport: cli.http.Transport, Timeout: cli.http.Timeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		pc.Error = err.Error()
+		return
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		pc.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	pc.Status = resp.StatusCode
+	if resp.StatusCode != http.StatusOK {
+		pc.Error = resp.Status
+		return
+	}
+	if doc, err = goquery.NewDocumentFromReader(resp.Body); err != nil {
+		pc.Error = err.Error()
+		return
+	}
+	pc.OK = true
+	for _, e := range p.expects(cli.selectors()) {
+		sc := SelectorCheck{Field: e.field, Selector: e.selector, Pattern: e.pattern, Expected: e.min, Actual: e.count(doc)}
+		sc.OK = sc.Actual >= e.min
+		pc.OK = pc.OK && sc.OK
+		pc.Selectors = append(pc.Selectors, sc)
+	}
+	return
+}
+
+// count matches of the selector, the ones matching the pattern too when there is one
+func (e expect) count(doc *goquery.Document) (n int) {
+	sel := doc.Find(e.selector)
+	if e.pattern == "" {
+		return sel.Size()
+	}
+	re, err := regexp.Compile(e.pattern)
+	if err != nil {
+		return 0
+	}
+	sel.Each(func(_ int, s *goquery.Selection) {
+		v := s.Text()
+		if e.attr != "" {
+			v = s.AttrOr(e.attr, "")
+		}
+		if re.MatchString(v) {
+			n++
+		}
+	})
+	return
+}
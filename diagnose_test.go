@@ -0,0 +1,112 @@
+package dnevnik76
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bvp/dnevnik76-api/parse"
+)
+
+func TestClient_Diagnose(t *testing.T) {
+	pages := map[string]string{
+		"/accounts/login/": `<form class="login__form"><input name="csrfmiddlewaretoken" value="t"/></form>`,
+		"/ajax/kladr/":     `<select><option value="2">Ярославль</option></select>`,
+		"/ajax/school/2/":  `<select><optgroup label="Школы"><option value="760215">Школа № 83</option></optgroup></select>`,
+		"/homework/": `<body onload="loadSubjects('/ajax/subj/121', true)"><div id="auth_info"><span id="role">Учащийся (7А)</span></div>
+			<div id="eduyear"><span id="curedy">2022-2023 учебный год</span></div>
+			<div id="homework_list"><table class="list"><tbody></tbody></table></div></body>`,
+		"/ajax/subj/121": `<select><option value="11">Математика</option></select>`,
+		// layout changed: weeks are gone
+		"/marks/current/note/": `<div id="content"><h3>с 1 сентября 2022 г. по 28 октября 2022 г.</h3>
+			<select id="mark_range"><optgroup><option value="q1">1 четверть</option></optgroup></select>
+			<div id="marks"><section class="week"></section></div></div>`,
+		"/marks/current/list/": `<div id="marks"><div id="mark-row"><div class="mark-label">Физика</div></div></div>`,
+		"/marks/itog/":         `<div id="marks"><div id="wrap-col"><div id="wrap-marks"><div><div id="mark-row" name="11"></div></div></div></div></div>`,
+		"/messages/input": `<div id="content"><form><table class="list"><tbody>
+			<tr><td><input value="123456"/></td><td><a>Тема</a></td><td></td><td></td></tr></tbody></table></form></div>`,
+		"/messages/input/123456/": `<div id="msgview"><div class="msg-meta"><div class="msg-props"><div>Дата: 17 декабря 2018 г.</div><div>От: <a></a><a>Иванова М. П.</a></div></div></div>
+			<div class="msg-text">Текст</div></div>`,
+	}
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	cli.CurrentInfo = CurrentInfo{RegionID: 2, ClassID: 121}
+
+	d, err := cli.Diagnose(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.OK || len(d.Pages) != 11 {
+		t.Fatalf("diagnosis - %+v", d)
+	}
+	failed := d.Failed()
+	if len(failed) != 2 || failed[0].Page != "marks note" || failed[1].Page != "teachers" {
+		t.Fatalf("failed - %+v", failed)
+	}
+	if s := failed[0].Selectors; len(s) != 2 || s[0].Selector != "#marks > div.week div.dayofweek" || s[0].Expected != 1 || s[0].Actual != 0 {
+		t.Errorf("note selectors - %+v", s)
+	}
+	if failed[1].Status != http.StatusNotFound || failed[1].Error == "" {
+		t.Errorf("teachers - %+v", failed[1])
+	}
+	if msg := d.Pages[9]; msg.Page != "message" || msg.URL != urlMessages+"/123456/" || !msg.OK {
+		t.Errorf("message - %+v", msg)
+	}
+	var loadSubjects SelectorCheck
+	for _, sc := range d.Pages[3].Selectors {
+		if sc.Field == "info.loadSubjects" {
+			loadSubjects = sc
+		}
+	}
+	if loadSubjects.Selector != "body" || loadSubjects.Pattern == "" || loadSubjects.Actual != 1 || !loadSubjects.OK {
+		t.Errorf("pattern check - %+v", loadSubjects)
+	}
+
+	// hot-fix of the layout change
+	sel := parse.DefaultProfile()
+	sel.Note.Days = "#marks > section.week > div.dayofweek"
+	cli.Selectors = &sel
+	pages["/marks/current/note/"] = strings.Replace(pages["/marks/current/note/"], `<section class="week"></section>`,
+		`<section class="week"><div class="dayofweek"><div class="weekday"><h3>Понедельник (5 сентября 2022 г.)</h3></div></div></section>`, 1)
+	if d, _ = cli.Diagnose(context.Background()); len(d.Failed()) != 1 {
+		t.Errorf("with profile - %+v", d.Failed())
+	}
+
+	// the subjects loader moved to another attribute
+	sel.Info.LoadSubjects = `initSubjects\('/ajax/subj/(?P<class>\d+)`
+	if d, _ = cli.Diagnose(context.Background()); len(d.Failed()) != 2 || d.Failed()[0].Selectors[0].Field != "info.loadSubjects" {
+		t.Errorf("pattern mismatch - %+v", d.Failed())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = cli.Diagnose(ctx); err != context.Canceled {
+		t.Errorf("canceled - %v", err)
+	}
+}
+
+func TestSitePages_CheckEveryField(t *testing.T) {
+	profile := parse.DefaultProfile()
+	checked := map[string]bool{}
+	for _, p := range sitePages {
+		for _, e := range p.expects(&profile) {
+			checked[e.field] = true
+			if e.selector == "" {
+				t.Errorf("%s: %s has no selector", p.name, e.field)
+			}
+		}
+	}
+	for _, f := range profile.Fields() {
+		if !checked[f.Name] {
+			t.Errorf("%s is not checked", f.Name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package digest builds and mails periodic summaries of diary activity
+package digest
+
+import (
+	"sort"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/snapshot"
+)
+
+// Source of diary records, usually *dnevnik76.Client
+type Source interface {
+	GetCurrentQuarter() string
+	GetMarksFor(p string) ([]dnevnik76.Mark, error)
+	GetMarksFinal() ([]dnevnik76.Mark, error)
+	GetMarksPeriods() ([]dnevnik76.Lperiod, error)
+	GetHomework() ([]dnevnik76.Homework, error)
+	GetMessages() ([]dnevnik76.Message, error)
+}
+
+// CourseMarks of one course within the digest window
+type CourseMarks struct {
+	CourseName string
+	Marks      []dnevnik76.Mark
+}
+
+// Digest of diary activity
+type Digest struct {
+	Student  string
+	Class    string
+	From     time.Time
+	To       time.Time
+	Marks    []CourseMarks
+	Final    []dnevnik76.Mark
+	Homework []dnevnik76.Homework
+	Unread   []dnevnik76.Message
+}
+
+// Empty reports whether there is nothing to tell
+func (d Digest) Empty() bool {
+	return len(d.Marks)+len(d.Final)+len(d.Homework)+len(d.Unread) == 0
+}
+
+// Collector gathers digest from source
+type Collector struct {
+	Source Source
+	// Student shown in the digest heading
+	Student string
+	Class   string
+	// Ahead is how many days of upcoming homework to include
+	Ahead int
+	// Seen keeps the final marks of the last digest, e.g. snapshot.FileStore of its own file.
+	// Teachers post final marks after the term ends, so the ones graded since the last digest
+	// are collected; without Seen or on the first run the ones of terms ended within the window.
+	Seen snapshot.Store
+}
+
+// Daily digest window ending now
+func Daily(now time.Time) (from, to time.Time) {
+	return now.AddDate(0, 0, -1), now
+}
+
+// Weekly digest window ending now
+func Weekly(now time.Time) (from, to time.Time) {
+	return now.AddDate(0, 0, -7), now
+}
+
+// Collect marks given and messages received within [from, to] and homework due in the next Ahead days.
+// Marks are fetched for every term overlapping [from, to], final marks are collected as told for Seen.
+func (c *Collector) Collect(from, to time.Time) (d Digest, err error) {
+	d = Digest{Student: c.Student, Class: c.Class, From: from, To: to}
+
+	list, err := c.Source.GetMarksPeriods()
+	if err != nil {
+		return
+	}
+	terms := dnevnik76.NewPeriods(list).FinalTerms()
+	var periods []string
+	for _, t := range terms {
+		if !t.Start.After(to) && !t.End.Before(from) {
+			periods = append(periods, t.Period)
+		}
+	}
+	if len(periods) == 0 {
+		periods = []string{c.Source.GetCurrentQuarter()}
+	}
+	byCourse := map[string]int{}
+	for _, p := range periods {
+		var marks []dnevnik76.Mark
+		if marks, err = c.Source.GetMarksFor(p); err != nil {
+			return
+		}
+		for _, m := range marks {
+			if len(m.Grade) == 0 || !within(m.Date, from, to) {
+				continue
+			}
+			i, ok := byCourse[m.CourseName]
+			if !ok {
+				i = len(d.Marks)
+				byCourse[m.CourseName] = i
+				d.Marks = append(d.Marks, CourseMarks{CourseName: m.CourseName})
+			}
+			d.Marks[i].Marks = append(d.Marks[i].Marks, m)
+		}
+	}
+	sort.Slice(d.Marks, func(i, j int) bool { return d.Marks[i].CourseName < d.Marks[j].CourseName })
+
+	final, err := c.Source.GetMarksFinal()
+	if err != nil {
+		return
+	}
+	var last *snapshot.Snapshot
+	if c.Seen != nil {
+		if last, err = c.Seen.Load(); err != nil {
+			return
+		}
+	}
+	if last != nil {
+		for _, ch := range snapshot.Diff(last, &snapshot.Snapshot{Final: final}).Final {
+			if ch.New != nil && graded(*ch.New) {
+				d.Final = append(d.Final, *ch.New)
+			}
+		}
+	} else {
+		for _, m := range final {
+			if graded(m) && termEnded(terms, m, from, to) {
+				d.Final = append(d.Final, m)
+			}
+		}
+	}
+
+	hws, err := c.Source.GetHomework()
+	if err != nil {
+		return
+	}
+	due := to.AddDate(0, 0, c.Ahead)
+	for _, h := range hws {
+		if within(h.Date, to, due) {
+			d.Homework = append(d.Homework, h)
+		}
+	}
+	sort.SliceStable(d.Homework, func(i, j int) bool { return d.Homework[i].Date.Before(d.Homework[j].Date) })
+
+	messages, err := c.Source.GetMessages()
+	if err != nil {
+		return
+	}
+	for _, m := range messages {
+		if m.IsUnread && within(m.Date, from, to) {
+			d.Unread = append(d.Unread, m)
+		}
+	}
+	if c.Seen != nil {
+		err = c.Seen.Save(&snapshot.Snapshot{TakenAt: to, Final: final})
+	}
+	return
+}
+
+// graded reports whether final mark is given, not yet graded slots hold 0
+func graded(m dnevnik76.Mark) bool {
+	return len(m.Grade) > 0 && m.Grade[0] > 0
+}
+
+// termEnded reports whether the term of final mark ended within [from, to], the last one for annual marks
+func termEnded(terms dnevnik76.Periods, m dnevnik76.Mark, from, to time.Time) bool {
+	n := m.Quarter
+	if m.Annual {
+		n = len(terms)
+	}
+	return n >= 1 && n <= len(terms) && within(terms[n-1].End, from, to)
+}
+
+// within compares calendar days, both boundaries included
+func within(date, from, to time.Time) bool {
+	day := func(t time.Time) time.Time {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return !day(date).Before(day(from)) && !day(date).After(day(to))
+}
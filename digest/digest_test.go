@@ -0,0 +1,226 @@
+package digest
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/snapshot"
+)
+
+type fakeSource struct {
+	final []dnevnik76.Mark
+}
+
+var now = time.Date(2022, time.September, 11, 18, 0, 0, 0, time.Local)
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{final: []dnevnik76.Mark{
+		{CourseName: "Физика", Quarter: 1, Grade: []int8{5}},
+		{CourseName: "Физика", Quarter: 2, Grade: []int8{0}},
+		{CourseName: "Физика", Annual: true, Grade: []int8{0}},
+	}}
+}
+
+func (*fakeSource) GetCurrentQuarter() string { return "q1" }
+func (*fakeSource) GetMarksFor(p string) ([]dnevnik76.Mark, error) {
+	if p == "q2" {
+		return []dnevnik76.Mark{{CourseName: "Химия", Date: time.Date(2022, time.November, 8, 0, 0, 0, 0, time.Local), Grade: []int8{4}}}, nil
+	}
+	return []dnevnik76.Mark{
+		{CourseName: "Физика", Date: now.AddDate(0, 0, -2), Grade: []int8{5}},
+		{CourseName: "Алгебра", Date: now.AddDate(0, 0, -3), Grade: []int8{4, 5}},
+		{CourseName: "Алгебра", Date: now.AddDate(0, 0, -4)},
+		{CourseName: "Химия", Date: now.AddDate(0, 0, -10), Grade: []int8{3}},
+		{CourseName: "Физика", Date: time.Date(2022, time.October, 27, 0, 0, 0, 0, time.Local), Grade: []int8{4}},
+	}, nil
+}
+func (s *fakeSource) GetMarksFinal() ([]dnevnik76.Mark, error) {
+	return append([]dnevnik76.Mark(nil), s.final...), nil
+}
+func (*fakeSource) GetMarksPeriods() ([]dnevnik76.Lperiod, error) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2022, m, d, 0, 0, 0, 0, time.Local) }
+	return []dnevnik76.Lperiod{
+		{Name: "1 полугодие", Period: "h1", Start: day(time.September, 1), End: day(time.December, 28)},
+		{Name: "1 четверть", Period: "q1", Start: day(time.September, 1), End: day(time.October, 28)},
+		{Name: "2 четверть", Period: "q2", Start: day(time.November, 7), End: day(time.December, 28)},
+	}, nil
+}
+func (*fakeSource) GetHomework() ([]dnevnik76.Homework, error) {
+	return []dnevnik76.Homework{
+		{CourseName: "Физика", Date: now.AddDate(0, 0, 1), Homework: "§ 3 <упр. 2>"},
+		{CourseName: "Химия", Date: now.AddDate(0, 0, 10), Homework: "§ 9"},
+	}, nil
+}
+func (*fakeSource) GetMessages() ([]dnevnik76.Message, error) {
+	return []dnevnik76.Message{
+		{From: "Директор", Subject: "Собрание", Date: now.AddDate(0, 0, -1), IsUnread: true},
+		{From: "Директор", Subject: "Прочитано", Date: now.AddDate(0, 0, -1)},
+	}, nil
+}
+
+func TestCollector_Collect(t *testing.T) {
+	c := &Collector{Source: newFakeSource(), Student: "Петя", Ahead: 3}
+	d, err := c.Collect(Weekly(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Marks) != 2 || d.Marks[0].CourseName != "Алгебра" || len(d.Marks[0].Marks) != 1 {
+		t.Errorf("marks - %+v", d.Marks)
+	}
+	if len(d.Final) != 0 || len(d.Homework) != 1 || len(d.Unread) != 1 {
+		t.Errorf("digest - %+v", d)
+	}
+
+	quarterEnd := time.Date(2022, time.October, 30, 18, 0, 0, 0, time.Local)
+	if d, err = c.Collect(Weekly(quarterEnd)); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Final) != 1 || d.Final[0].Quarter != 1 {
+		t.Errorf("final marks - %+v", d.Final)
+	}
+	// the window crosses the end of the quarter and the holidays
+	if d, err = c.Collect(quarterEnd.AddDate(0, 0, -4), quarterEnd.AddDate(0, 0, 9)); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Marks) != 2 || d.Marks[0].CourseName != "Физика" || d.Marks[1].CourseName != "Химия" {
+		t.Errorf("marks of both quarters - %+v", d.Marks)
+	}
+	if d, err = c.Collect(Weekly(quarterEnd.AddDate(0, 0, 28))); err != nil || !d.Empty() {
+		t.Errorf("quiet week - %+v (%v)", d, err)
+	}
+}
+
+type memSeen struct {
+	last *snapshot.Snapshot
+}
+
+func (s *memSeen) Load() (*snapshot.Snapshot, error) { return s.last, nil }
+func (s *memSeen) Save(snap *snapshot.Snapshot) error {
+	s.last = snap
+	return nil
+}
+
+func TestCollector_CollectSeen(t *testing.T) {
+	src := newFakeSource()
+	c := &Collector{Source: src, Seen: &memSeen{}}
+	if d, err := c.Collect(Weekly(now)); err != nil || len(d.Final) != 0 {
+		t.Fatalf("first run - %+v (%v)", d.Final, err)
+	}
+
+	// the quarter mark is posted weeks after the quarter ended
+	src.final[1].Grade = []int8{4}
+	late := time.Date(2023, time.January, 20, 18, 0, 0, 0, time.Local)
+	d, err := c.Collect(Weekly(late))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Final) != 1 || d.Final[0].Quarter != 2 {
+		t.Errorf("late final marks - %+v", d.Final)
+	}
+	if d, err = c.Collect(Weekly(late.AddDate(0, 0, 7))); err != nil || len(d.Final) != 0 {
+		t.Errorf("final marks again - %+v (%v)", d.Final, err)
+	}
+}
+
+// fakeSMTP accepts one message and sends its data to the channel
+func fakeSMTP(t *testing.T) (addr string, data <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				ch <- msg.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), ch
+}
+
+func TestSend(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	r, err := NewRenderer("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Mailer{Addr: addr, From: "bot@example.org", To: []string{"grandma@example.org"}, Subject: "Дневник за неделю"}
+	c := &Collector{Source: newFakeSource(), Student: "Петя", Ahead: 3}
+	from, to := Weekly(now)
+	if err = Send(c, r, m, from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Дневник за неделю" {
+		t.Errorf("subject - %q", subject)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type - %q", mediaType)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(p)
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[ct] = string(body)
+	}
+	if !strings.Contains(parts["text/plain"], "Алгебра: 4 5 (08.09.2022)") {
+		t.Errorf("text part:\n%s", parts["text/plain"])
+	}
+	if !strings.Contains(parts["text/html"], "§ 3 &lt;упр. 2&gt;") || !strings.Contains(parts["text/html"], "Собрание") {
+		t.Errorf("html part:\n%s", parts["text/html"])
+	}
+}
@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer sends digests through SMTP server
+type Mailer struct {
+	// Addr of SMTP server as host:port
+	Addr string
+	// Auth is optional, e.g. smtp.PlainAuth
+	Auth    smtp.Auth
+	From    string
+	To      []string
+	Subject string
+}
+
+// Send text and HTML versions as one multipart/alternative message
+func (m *Mailer) Send(text, html string) error {
+	msg, err := m.Message(text, html, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, m.To, msg)
+}
+
+// Message to build RFC 5322 message with text and HTML parts
+func (m *Mailer) Message(text, html string, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	}
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err = qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+		if err = qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", m.From},
+		{"To", strings.Join(m.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// Send to collect digest for [from, to], render it and mail
+func Send(c *Collector, r *Renderer, m *Mailer, from, to time.Time) error {
+	d, err := c.Collect(from, to)
+	if err != nil {
+		return err
+	}
+	text, html, err := r.Render(d)
+	if err != nil {
+		return err
+	}
+	return m.Send(text, html)
+}
@@ -0,0 +1,113 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	"text/template"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+var funcs = map[string]interface{}{
+	"date": func(m interface{ Format(string) string }) string {
+		return m.Format("02.01.2006")
+	},
+	"grades": func(m dnevnik76.Mark) string {
+		var s []string
+		for _, g := range m.Grade {
+			s = append(s, strconv.Itoa(int(g)))
+		}
+		return strings.Join(s, " ")
+	},
+	"period": func(m dnevnik76.Mark) string {
+		if m.Annual {
+			return "Годовая"
+		}
+		return fmt.Sprintf("%d четверть", m.Quarter)
+	},
+}
+
+// TextTemplate of plain-text digest
+const TextTemplate = `Дневник{{with .Student}}: {{.}}{{end}}{{with .Class}} ({{.}}){{end}}
+{{date .From}} – {{date .To}}
+{{if .Marks}}
+Оценки
+{{range .Marks}}  {{.CourseName}}:{{range .Marks}} {{grades .}} ({{date .Date}}){{end}}
+{{end}}{{end}}{{if .Final}}
+Итоговые оценки
+{{range .Final}}  {{.CourseName}}, {{period .}}: {{grades .}}
+{{end}}{{end}}{{if .Homework}}
+Домашние задания
+{{range .Homework}}  {{date .Date}} {{.CourseName}}: {{.Homework}}
+{{end}}{{end}}{{if .Unread}}
+Непрочитанные сообщения
+{{range .Unread}}  {{date .Date}} {{.From}}: {{.Subject}}
+{{end}}{{end}}{{if .Empty}}
+Нет новых событий
+{{end}}`
+
+// HTMLTemplate of HTML digest
+const HTMLTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Дневник</title></head>
+<body style="font-family: sans-serif">
+<h2>Дневник{{with .Student}}: {{.}}{{end}}{{with .Class}} ({{.}}){{end}}</h2>
+<p>{{date .From}} – {{date .To}}</p>
+{{if .Marks}}<h3>Оценки</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+{{range .Marks}}<tr><td>{{.CourseName}}</td><td>{{range .Marks}}<b>{{grades .}}</b> <small>{{date .Date}}</small> {{end}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Final}}<h3>Итоговые оценки</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+{{range .Final}}<tr><td>{{.CourseName}}</td><td>{{period .}}</td><td><b>{{grades .}}</b></td></tr>
+{{end}}</table>{{end}}
+{{if .Homework}}<h3>Домашние задания</h3>
+<ul>
+{{range .Homework}}<li>{{date .Date}} <b>{{.CourseName}}</b>: {{.Homework}}</li>
+{{end}}</ul>{{end}}
+{{if .Unread}}<h3>Непрочитанные сообщения</h3>
+<ul>
+{{range .Unread}}<li>{{date .Date}} {{.From}}: {{.Subject}}</li>
+{{end}}</ul>{{end}}
+{{if .Empty}}<p>Нет новых событий</p>{{end}}
+</body></html>
+`
+
+// Renderer of digest into plain text and HTML
+type Renderer struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// NewRenderer with the given templates, empty ones fall back to defaults
+func NewRenderer(textTmpl, htmlTmpl string) (*Renderer, error) {
+	if textTmpl == "" {
+		textTmpl = TextTemplate
+	}
+	if htmlTmpl == "" {
+		htmlTmpl = HTMLTemplate
+	}
+	t, err := template.New("text").Funcs(funcs).Parse(textTmpl)
+	if err != nil {
+		return nil, err
+	}
+	h, err := htmltemplate.New("html").Funcs(funcs).Parse(htmlTmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{text: t, html: h}, nil
+}
+
+// Render digest
+func (r *Renderer) Render(d Digest) (text, html string, err error) {
+	var tb, hb bytes.Buffer
+	if err = r.text.Execute(&tb, d); err != nil {
+		return
+	}
+	if err = r.html.Execute(&hb, d); err != nil {
+		return
+	}
+	return tb.String(), hb.String(), nil
+}
@@ -0,0 +1,175 @@
+// Package export writes marks to CSV and XLSX spreadsheets
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+const bom = "\xef\xbb\xbf"
+
+// Options of export
+type Options struct {
+	// BOM prepends UTF-8 byte order mark to CSV so Excel detects encoding
+	BOM bool
+	// Russian headers and date format
+	Russian bool
+	// Comma separates CSV fields, defaults to ','
+	Comma rune
+}
+
+func (o Options) header(en, ru string) string {
+	if o.Russian {
+		return ru
+	}
+	return en
+}
+
+func (o Options) date(m dnevnik76.Mark) string {
+	if m.Date.IsZero() {
+		return ""
+	}
+	if o.Russian {
+		return m.Date.Format("02.01.2006")
+	}
+	return m.Date.Format("2006-01-02")
+}
+
+// period of final mark
+func (o Options) period(m dnevnik76.Mark) string {
+	switch {
+	case m.Annual:
+		return o.header("Year", "Год")
+	case m.Quarter > 0:
+		return fmt.Sprintf("%d %s", m.Quarter, o.header("quarter", "четверть"))
+	}
+	return ""
+}
+
+// WriteCSV of marks or final marks in long format, one row per grade,
+// not yet graded final marks are skipped
+func WriteCSV(w io.Writer, marks []dnevnik76.Mark, opts Options) error {
+	if opts.BOM {
+		if _, err := io.WriteString(w, bom); err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	cw.Write([]string{
+		opts.header("School year", "Учебный год"),
+		opts.header("Date", "Дата"),
+		opts.header("Period", "Период"),
+		opts.header("Course", "Предмет"),
+		opts.header("Grade", "Оценка"),
+		opts.header("Grade no.", "№ оценки"),
+		opts.header("Subject", "Тема"),
+	})
+	for _, m := range marks {
+		year := ""
+		if m.SYear > 0 {
+			year = fmt.Sprintf("%d-%d", m.SYear, m.EYear)
+		}
+		for i, g := range m.Grade {
+			if g <= 0 {
+				continue
+			}
+			cw.Write([]string{
+				year,
+				opts.date(m),
+				opts.period(m),
+				strings.TrimSpace(m.CourseName),
+				strconv.Itoa(int(g)),
+				strconv.Itoa(i + 1),
+				strings.TrimSpace(m.Subject),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Journal is a wide table with courses as rows
+type Journal struct {
+	Name    string
+	Columns []string
+	Rows    []JournalRow
+}
+
+// JournalRow of a course
+type JournalRow struct {
+	Course string
+	Cells  []string
+}
+
+// MarksJournal to pivot marks into courses by dates
+func MarksJournal(marks []dnevnik76.Mark, opts Options) Journal {
+	sorted := append([]dnevnik76.Mark(nil), marks...)
+	sort.Stable(dnevnik76.MarksByDate(sorted))
+	return pivot(opts.header("Marks", "Оценки"), sorted, opts.date)
+}
+
+// FinalJournal to pivot final marks into courses by periods
+func FinalJournal(marks []dnevnik76.Mark, opts Options) Journal {
+	sorted := append([]dnevnik76.Mark(nil), marks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return periodOrder(sorted[i]) < periodOrder(sorted[j])
+	})
+	return pivot(opts.header("Final marks", "Итоговые оценки"), sorted, opts.period)
+}
+
+func periodOrder(m dnevnik76.Mark) int {
+	if m.Annual {
+		return 100
+	}
+	return m.Quarter
+}
+
+// pivot sorted marks, columns keep the order they first appear in, not yet graded slots are skipped
+func pivot(name string, marks []dnevnik76.Mark, column func(dnevnik76.Mark) string) Journal {
+	j := Journal{Name: name}
+	cols, rows := map[string]int{}, map[string]int{}
+	cells := map[[2]int][]string{}
+	for _, m := range marks {
+		var grades []string
+		for _, g := range m.Grade {
+			if g > 0 {
+				grades = append(grades, strconv.Itoa(int(g)))
+			}
+		}
+		if len(grades) == 0 {
+			continue
+		}
+		col := column(m)
+		ci, ok := cols[col]
+		if !ok {
+			ci = len(j.Columns)
+			cols[col] = ci
+			j.Columns = append(j.Columns, col)
+		}
+		course := strings.TrimSpace(m.CourseName)
+		ri, ok := rows[course]
+		if !ok {
+			ri = len(j.Rows)
+			rows[course] = ri
+			j.Rows = append(j.Rows, JournalRow{Course: course})
+		}
+		cells[[2]int{ri, ci}] = append(cells[[2]int{ri, ci}], grades...)
+	}
+	for ri := range j.Rows {
+		j.Rows[ri].Cells = make([]string, len(j.Columns))
+		for ci := range j.Columns {
+			j.Rows[ri].Cells[ci] = strings.Join(cells[[2]int{ri, ci}], " ")
+		}
+	}
+	sort.SliceStable(j.Rows, func(a, b int) bool { return j.Rows[a].Course < j.Rows[b].Course })
+	return j
+}
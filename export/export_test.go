@@ -0,0 +1,115 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func day(d int) time.Time {
+	return time.Date(2022, time.September, d, 0, 0, 0, 0, time.Local)
+}
+
+var marks = dnevnik76.MarksByDate{
+	{SYear: 2022, EYear: 2023, CourseName: "Физика", Date: day(6), Grade: []int8{5}},
+	{SYear: 2022, EYear: 2023, CourseName: "Алгебра", Date: day(5), Grade: []int8{4, 5}, Subject: "Дроби"},
+	{SYear: 2022, EYear: 2023, CourseName: "Физика", Date: day(5)},
+}
+
+var final = []dnevnik76.Mark{
+	{CourseName: "Физика", Annual: true, Grade: []int8{5}},
+	{CourseName: "Физика", Quarter: 2, Grade: []int8{5}},
+	{CourseName: "Физика", Quarter: 1, Grade: []int8{4}},
+	{CourseName: "Алгебра", Quarter: 1, Grade: []int8{3}},
+	{CourseName: "Алгебра", Quarter: 2, Grade: []int8{0}},
+	{CourseName: "Алгебра", Annual: true, Grade: []int8{0}},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteCSV(&b, marks, Options{BOM: true, Russian: true, Comma: ';'}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "\xef\xbb\xbfУчебный год;Дата;") {
+		t.Errorf("header - %q", b.String()[:40])
+	}
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(b.String(), bom)))
+	r.Comma = ';'
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("rows - %q", rows)
+	}
+	if strings.Join(rows[2], "|") != "2022-2023|05.09.2022||Алгебра|4|1|Дроби" || rows[3][5] != "2" {
+		t.Errorf("rows - %q", rows)
+	}
+
+	b.Reset()
+	WriteCSV(&b, final, Options{})
+	if !strings.Contains(b.String(), ",Year,Физика,5,1,") || strings.Contains(b.String(), ",0,") ||
+		strings.Count(b.String(), "\n") != 5 {
+		t.Errorf("final csv:\n%s", b.String())
+	}
+}
+
+func TestFinalJournal(t *testing.T) {
+	j := FinalJournal(final, Options{Russian: true})
+	if strings.Join(j.Columns, "|") != "1 четверть|2 четверть|Год" {
+		t.Errorf("columns - %q", j.Columns)
+	}
+	if j.Rows[0].Course != "Алгебра" || strings.Join(j.Rows[0].Cells, "|") != "3||" {
+		t.Errorf("rows - %+v", j.Rows)
+	}
+}
+
+func TestWriteMarksXLSX(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMarksXLSX(&b, marks, Options{Russian: true}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if files[name] == "" {
+			t.Errorf("no %s", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="B1" t="inlineStr"><is><t>05.09.2022</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t>Алгебра</t></is></c><c r="B2" t="inlineStr"><is><t>4 5</t></is></c>`,
+		`<c r="C3"><v>5</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("no %s in\n%s", want, sheet)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="Оценки"`) {
+		t.Errorf("workbook - %s", files["xl/workbook.xml"])
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) - %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// WriteMarksXLSX with marks journal sheet, courses by dates
+func WriteMarksXLSX(w io.Writer, marks []dnevnik76.Mark, opts Options) error {
+	return WriteXLSX(w, opts, MarksJournal(marks, opts))
+}
+
+// WriteFinalXLSX with final marks journal sheet, courses by periods
+func WriteFinalXLSX(w io.Writer, marks []dnevnik76.Mark, opts Options) error {
+	return WriteXLSX(w, opts, FinalJournal(marks, opts))
+}
+
+// WriteXLSX workbook with a sheet per journal
+func WriteXLSX(w io.Writer, opts Options, journals ...Journal) error {
+	zw := zip.NewWriter(w)
+	var sheets, rels, types strings.Builder
+	for i, j := range journals {
+		n := i + 1
+		fmt.Fprintf(&sheets, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheetName(j.Name, n)), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+	}
+
+	files := []struct {
+		name, body string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+	}
+	for i, j := range journals {
+		files = append(files, struct{ name, body string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet(j, opts)})
+	}
+
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// sheet XML with header row and one row per course, single grades become numbers
+func sheet(j Journal, opts Options) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane xSplit="1" ySplit="1" topLeftCell="B2" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<cols><col min="1" max="1" width="30" customWidth="1"/></cols><sheetData>`)
+
+	row := func(r int, values []string) {
+		fmt.Fprintf(&b, `<row r="%d">`, r)
+		for c, v := range values {
+			if v == "" {
+				continue
+			}
+			ref := column(c) + strconv.Itoa(r)
+			if _, err := strconv.Atoi(v); err == nil && r > 1 && c > 0 {
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, v)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, escape(v))
+		}
+		b.WriteString(`</row>`)
+	}
+	row(1, append([]string{opts.header("Course", "Предмет")}, j.Columns...))
+	for i, r := range j.Rows {
+		row(i+2, append([]string{r.Course}, r.Cells...))
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// column letter for zero based index
+func column(i int) string {
+	s := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
+
+// sheetName limited to Excel rules
+func sheetName(name string, n int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		name = fmt.Sprintf("Sheet%d", n)
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
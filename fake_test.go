@@ -0,0 +1,36 @@
+package dnevnik76
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// rewriteTransport sends every request to the test server
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newFakeClient to get client talking to handler instead of my.dnevnik76.ru
+func newFakeClient(t *testing.T, handler http.Handler) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	u, _ = url.Parse(urlLogin)
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		Username: "08331111",
+		SchoolID: 760215,
+		http:     &http.Client{Transport: rewriteTransport{target: target}, Jar: jar},
+		cache:    newCache(),
+	}
+}
@@ -0,0 +1,219 @@
+package ics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// FeedSource of feed data, usually *dnevnik76.Client
+type FeedSource interface {
+	GetHomework() ([]dnevnik76.Homework, error)
+	GetMarksPeriods() ([]dnevnik76.Lperiod, error)
+}
+
+// FeedServer serves per-user calendar feeds at .../<token>.ics.
+// Feeds are rebuilt on a background schedule, requests never reach dnevnik76.ru
+// except for the very first one of a feed.
+type FeedServer struct {
+	// Refresh interval of background rebuilds
+	Refresh  time.Duration
+	Homework HomeworkStyle
+	// OnError is called when a feed rebuild fails
+	OnError func(name string, err error)
+
+	mu    sync.RWMutex
+	feeds map[string]*feed
+}
+
+type feed struct {
+	name string
+	src  FeedSource
+	// building lets one build at a time call the source, see dnevnik76.Locked
+	building sync.Mutex
+
+	mu       sync.Mutex
+	body     []byte
+	etag     string
+	hash     string
+	modified time.Time
+}
+
+// NewFeedServer with hourly refresh
+func NewFeedServer() *FeedServer {
+	return &FeedServer{Refresh: time.Hour, feeds: map[string]*feed{}}
+}
+
+// NewToken to get an unguessable feed token
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// feeds are looked up by token hash so tokens are never compared directly
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add feed named name served at <token>.ics
+func (s *FeedServer) Add(token, name string, src FeedSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feeds[tokenKey(token)] = &feed{name: name, src: src}
+}
+
+// Remove feed
+func (s *FeedServer) Remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.feeds, tokenKey(token))
+}
+
+// Run to rebuild every feed each Refresh until ctx is done
+func (s *FeedServer) Run(ctx context.Context) error {
+	t := time.NewTicker(s.Refresh)
+	defer t.Stop()
+	for {
+		s.RefreshAll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// RefreshAll feeds now
+func (s *FeedServer) RefreshAll() {
+	s.mu.RLock()
+	feeds := make([]*feed, 0, len(s.feeds))
+	for _, f := range s.feeds {
+		feeds = append(feeds, f)
+	}
+	s.mu.RUnlock()
+	for _, f := range feeds {
+		if err := s.build(f, true); err != nil && s.OnError != nil {
+			s.OnError(f.name, err)
+		}
+	}
+}
+
+// build feed calendar, Last-Modified moves only when the content changes.
+// Unless rebuild, a feed built by a concurrent request or refresh meanwhile is kept.
+func (s *FeedServer) build(f *feed, rebuild bool) error {
+	f.building.Lock()
+	defer f.building.Unlock()
+	if !rebuild && f.built() {
+		return nil
+	}
+	hws, err := f.src.GetHomework()
+	if err != nil {
+		return err
+	}
+	periods, err := f.src.GetMarksPeriods()
+	if err != nil {
+		return err
+	}
+	render := func(stamp time.Time) []byte {
+		c := New(f.name)
+		c.Homework = s.Homework
+		c.Stamp = stamp
+		c.AddPeriods(dnevnik76.NewPeriods(periods))
+		c.AddHomework(hws)
+		return c.Bytes()
+	}
+	sum := sha256.Sum256(render(time.Unix(0, 0)))
+	hash := hex.EncodeToString(sum[:])
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if hash == f.hash {
+		return nil
+	}
+	f.hash = hash
+	f.modified = time.Now().UTC().Truncate(time.Second)
+	f.body = render(f.modified)
+	f.etag = fmt.Sprintf(`"%s"`, hash[:32])
+	return nil
+}
+
+func (f *feed) built() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.body != nil
+}
+
+// ServeHTTP to serve feed by token in the last path segment
+func (s *FeedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimSuffix(path.Base(r.URL.Path), ".ics")
+	s.mu.RLock()
+	f, ok := s.feeds[tokenKey(token)]
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !f.built() {
+		if err := s.build(f, false); err != nil {
+			if s.OnError != nil {
+				s.OnError(f.name, err)
+			}
+			http.Error(w, "feed is not available yet", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	body, etag, modified := f.body, f.etag, f.modified
+	f.mu.Unlock()
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", modified.Format(http.TimeFormat))
+	h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(s.Refresh.Seconds())))
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "text/calendar; charset=utf-8")
+	h.Set("Content-Length", fmt.Sprint(len(body)))
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == etag || t == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !modified.After(ims)
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package ics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+type fakeFeedSource struct {
+	mu       sync.Mutex
+	calls    int
+	homework []dnevnik76.Homework
+}
+
+func (f *fakeFeedSource) GetHomework() ([]dnevnik76.Homework, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.homework, nil
+}
+
+func (f *fakeFeedSource) GetMarksPeriods() ([]dnevnik76.Lperiod, error) {
+	return []dnevnik76.Lperiod{{Name: "1 четверть", Period: "q1", Start: day(time.September, 1), End: day(time.October, 28)}}, nil
+}
+
+func TestFeedServer(t *testing.T) {
+	src := &fakeFeedSource{homework: homework[:1]}
+	fs := NewFeedServer()
+	token, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.Add(token, "Петя", src)
+	srv := httptest.NewServer(http.StripPrefix("/feeds/", fs))
+	defer srv.Close()
+
+	get := func(path string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := get("/feeds/wrong.ics", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown token - %s", resp.Status)
+	}
+	resp := get("/feeds/"+token+".ics", nil)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar") {
+		t.Fatalf("feed - %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	etag, modified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	for _, h := range []map[string]string{{"If-None-Match": etag}, {"If-Modified-Since": modified}} {
+		if resp = get("/feeds/"+token+".ics", h); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("conditional %v - %s", h, resp.Status)
+		}
+	}
+
+	fs.RefreshAll()
+	if resp = get("/feeds/"+token+".ics", nil); resp.Header.Get("ETag") != etag || resp.Header.Get("Last-Modified") != modified {
+		t.Error("unchanged feed got new validators")
+	}
+	if src.calls != 2 {
+		t.Errorf("source calls - %d, want 2", src.calls)
+	}
+
+	src.mu.Lock()
+	src.homework = homework
+	src.mu.Unlock()
+	fs.RefreshAll()
+	if resp = get("/feeds/"+token+".ics", map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Errorf("changed feed - %s, etag %s", resp.Status, resp.Header.Get("ETag"))
+	}
+
+	fs.Remove(token)
+	if resp = get("/feeds/"+token+".ics", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("removed feed - %s", resp.Status)
+	}
+}
+
+// exclusiveFeedSource fails on concurrent calls like a shared *dnevnik76.Client would race
+type exclusiveFeedSource struct {
+	busy, overlapped, calls int32
+}
+
+func (f *exclusiveFeedSource) GetHomework() ([]dnevnik76.Homework, error) {
+	if !atomic.CompareAndSwapInt32(&f.busy, 0, 1) {
+		atomic.StoreInt32(&f.overlapped, 1)
+		return nil, nil
+	}
+	atomic.AddInt32(&f.calls, 1)
+	time.Sleep(5 * time.Millisecond)
+	atomic.StoreInt32(&f.busy, 0)
+	return homework[:1], nil
+}
+
+func (f *exclusiveFeedSource) GetMarksPeriods() ([]dnevnik76.Lperiod, error) { return nil, nil }
+
+func TestFeedServer_Concurrent(t *testing.T) {
+	src := &exclusiveFeedSource{}
+	fs := NewFeedServer()
+	fs.Add("token", "Петя", src)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			fs.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feeds/token.ics", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("feed - %d", w.Code)
+			}
+		}()
+	}
+	fs.RefreshAll()
+	wg.Wait()
+	if src.overlapped != 0 {
+		t.Error("source called concurrently")
+	}
+	if src.calls > 2 {
+		t.Errorf("source calls - %d, want the first build and the refresh only", src.calls)
+	}
+}
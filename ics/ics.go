@@ -0,0 +1,224 @@
+// Package ics renders homework, lessons and academic periods as iCalendar
+package ics
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+	uidDomain      = "dnevnik76.ru"
+)
+
+// HomeworkStyle of homework components
+type HomeworkStyle int
+
+const (
+	// HomeworkTodo renders homework as VTODO due on its date
+	HomeworkTodo HomeworkStyle = iota
+	// HomeworkEvent renders homework as all-day VEVENT
+	HomeworkEvent
+)
+
+// Lesson of timetable with known time
+type Lesson struct {
+	Start      time.Time
+	End        time.Time
+	CourseName string
+	Subject    string
+	Homework   string
+	Room       string
+}
+
+// LessonFromSchedule to get a timed lesson, ok is false when schedule has no time of day
+func LessonFromSchedule(s dnevnik76.Schedule, courseName string, length time.Duration) (l Lesson, ok bool) {
+	h, m, sec := s.Date.Clock()
+	if h == 0 && m == 0 && sec == 0 {
+		return
+	}
+	return Lesson{
+		Start:      s.Date,
+		End:        s.Date.Add(length),
+		CourseName: courseName,
+		Subject:    s.Subject,
+		Homework:   s.Homework,
+	}, true
+}
+
+// Calendar being built
+type Calendar struct {
+	Name     string
+	Homework HomeworkStyle
+	// Stamp is DTSTAMP of every component, keep it fixed for byte-stable output
+	Stamp time.Time
+
+	components []component
+	uids       map[string]int
+}
+
+type component struct {
+	kind  string
+	props [][2]string
+}
+
+// New calendar
+func New(name string) *Calendar {
+	return &Calendar{Name: name, uids: map[string]int{}}
+}
+
+// uid to get stable unique id for key, repeated keys get a sequence suffix
+func (c *Calendar) uid(key string) string {
+	n := c.uids[key]
+	c.uids[key] = n + 1
+	if n > 0 {
+		key = fmt.Sprintf("%s/%d", key, n)
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:]) + "@" + uidDomain
+}
+
+// AddHomework as VTODO or all-day VEVENT depending on Homework style
+func (c *Calendar) AddHomework(hws []dnevnik76.Homework) {
+	for _, h := range hws {
+		course := strings.TrimSpace(h.CourseName)
+		uid := c.uid(fmt.Sprintf("homework/%d/%d/%s/%s", h.SchoolID, h.ClassID, h.Date.Format(dateFormat), course))
+		props := [][2]string{
+			{"UID", uid},
+			{"SUMMARY", escape(fmt.Sprintf("%s: %s", course, h.Homework))},
+		}
+		if h.Subject != "" {
+			props = append(props, [2]string{"DESCRIPTION", escape(h.Subject)})
+		}
+		props = append(props, [2]string{"CATEGORIES", "Домашнее задание"})
+		if c.Homework == HomeworkTodo {
+			props = append(props, [2]string{"DUE;VALUE=DATE", h.Date.Format(dateFormat)}, [2]string{"STATUS", "NEEDS-ACTION"})
+			c.components = append(c.components, component{kind: "VTODO", props: props})
+			continue
+		}
+		props = append(props,
+			[2]string{"DTSTART;VALUE=DATE", h.Date.Format(dateFormat)},
+			[2]string{"DTEND;VALUE=DATE", h.Date.AddDate(0, 0, 1).Format(dateFormat)},
+			[2]string{"TRANSP", "TRANSPARENT"},
+		)
+		c.components = append(c.components, component{kind: "VEVENT", props: props})
+	}
+}
+
+// AddPeriods as all-day events and holidays between terms as events too
+func (c *Calendar) AddPeriods(periods dnevnik76.Periods) {
+	for _, p := range periods {
+		c.allDay(fmt.Sprintf("period/%d/%d/%s", p.SchoolID, p.SYear, p.Period), p.Name, p.Kind().String(), p.Start, p.End)
+	}
+	for _, g := range periods.Terms().Gaps() {
+		c.allDay(fmt.Sprintf("holiday/%d/%s", g.After.SchoolID, g.Start.Format(dateFormat)), "Каникулы", "holiday", g.Start, g.End)
+	}
+}
+
+func (c *Calendar) allDay(key, summary, category string, start, end time.Time) {
+	c.components = append(c.components, component{kind: "VEVENT", props: [][2]string{
+		{"UID", c.uid(key)},
+		{"SUMMARY", escape(summary)},
+		{"CATEGORIES", escape(category)},
+		{"DTSTART;VALUE=DATE", start.Format(dateFormat)},
+		{"DTEND;VALUE=DATE", end.AddDate(0, 0, 1).Format(dateFormat)},
+		{"TRANSP", "TRANSPARENT"},
+	}})
+}
+
+// AddLessons as timed events
+func (c *Calendar) AddLessons(lessons []Lesson) {
+	for _, l := range lessons {
+		course := strings.TrimSpace(l.CourseName)
+		props := [][2]string{
+			{"UID", c.uid(fmt.Sprintf("lesson/%s/%s", l.Start.UTC().Format(dateTimeFormat), course))},
+			{"SUMMARY", escape(course)},
+			{"DTSTART", l.Start.UTC().Format(dateTimeFormat)},
+			{"DTEND", l.End.UTC().Format(dateTimeFormat)},
+		}
+		var desc []string
+		if l.Subject != "" {
+			desc = append(desc, "Тема: "+l.Subject)
+		}
+		if l.Homework != "" {
+			desc = append(desc, "Задание: "+l.Homework)
+		}
+		if len(desc) > 0 {
+			props = append(props, [2]string{"DESCRIPTION", escape(strings.Join(desc, "\n"))})
+		}
+		if l.Room != "" {
+			props = append(props, [2]string{"LOCATION", escape(l.Room)})
+		}
+		c.components = append(c.components, component{kind: "VEVENT", props: props})
+	}
+}
+
+// WriteTo to render calendar to w
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	line := func(name, value string) {
+		fold(&b, name+":"+value)
+	}
+	stamp := c.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//bvp//dnevnik76-api//RU")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	for _, comp := range c.components {
+		line("BEGIN", comp.kind)
+		line("DTSTAMP", stamp.UTC().Format(dateTimeFormat))
+		for _, p := range comp.props {
+			line(p[0], p[1])
+		}
+		line("END", comp.kind)
+	}
+	line("END", "VCALENDAR")
+	return b.WriteTo(w)
+}
+
+// Bytes of rendered calendar
+func (c *Calendar) Bytes() []byte {
+	var b bytes.Buffer
+	c.WriteTo(&b)
+	return b.Bytes()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(strings.TrimSpace(s))
+}
+
+// fold content line to 75 octets without splitting UTF-8 sequences
+func fold(b *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		b.WriteString(s[:i])
+		b.WriteString("\r\n ")
+		s = s[i:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
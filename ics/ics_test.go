@@ -0,0 +1,94 @@
+package ics
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2022, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func build(hws []dnevnik76.Homework, style HomeworkStyle) []byte {
+	c := New("Дневник")
+	c.Homework = style
+	c.Stamp = time.Date(2022, time.September, 1, 0, 0, 0, 0, time.UTC)
+	c.AddHomework(hws)
+	c.AddPeriods(dnevnik76.NewPeriods([]dnevnik76.Lperiod{
+		{SchoolID: 760215, SYear: 2022, Name: "1 четверть", Period: "q1", Start: day(time.September, 1), End: day(time.October, 28)},
+		{SchoolID: 760215, SYear: 2022, Name: "2 четверть", Period: "q2", Start: day(time.November, 7), End: day(time.December, 28)},
+	}))
+	c.AddLessons([]Lesson{{Start: time.Date(2022, 9, 5, 8, 30, 0, 0, time.UTC), End: time.Date(2022, 9, 5, 9, 15, 0, 0, time.UTC), CourseName: "Физика", Subject: "Сила, масса; ускорение"}})
+	return c.Bytes()
+}
+
+var homework = []dnevnik76.Homework{
+	{SchoolID: 760215, ClassID: 121, Date: day(time.September, 5), CourseName: "Физика", Homework: "§ 3, упр. 2"},
+	{SchoolID: 760215, ClassID: 121, Date: day(time.September, 5), CourseName: "Физика", Homework: "Повторить формулы"},
+}
+
+var reUID = regexp.MustCompile(`UID:(\S+)`)
+
+func TestCalendar_Render(t *testing.T) {
+	out := build(homework, HomeworkTodo)
+	s := strings.ReplaceAll(string(out), "\r\n ", "")
+
+	for _, want := range []string{
+		"BEGIN:VTODO",
+		`SUMMARY:Физика: § 3\, упр. 2`,
+		"DUE;VALUE=DATE:20220905",
+		"SUMMARY:Каникулы",
+		"DTSTART;VALUE=DATE:20221029",
+		"DTEND;VALUE=DATE:20221107",
+		"DTSTART:20220905T083000Z",
+		`DESCRIPTION:Тема: Сила\, масса\; ускорение`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("no %q in\n%s", want, s)
+		}
+	}
+	for _, line := range strings.Split(string(out), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line is not folded: %q", line)
+		}
+	}
+
+	uids := reUID.FindAllStringSubmatch(s, -1)
+	seen := map[string]bool{}
+	for _, u := range uids {
+		if seen[u[1]] {
+			t.Errorf("duplicate uid %s", u[1])
+		}
+		seen[u[1]] = true
+	}
+	if len(uids) != 6 {
+		t.Errorf("components - %d, want 6", len(uids))
+	}
+	// homework uids subscribed calendars already know
+	if uids[0][1] != "2b278dddb7b128e118373ea476487d3da76956df@"+uidDomain {
+		t.Errorf("homework uid %s", uids[0][1])
+	}
+
+	if again := build(homework, HomeworkTodo); !bytes.Equal(out, again) {
+		t.Error("regenerated calendar differs")
+	}
+	events := strings.ReplaceAll(string(build(homework, HomeworkEvent)), "\r\n ", "")
+	if reUID.FindString(events) != reUID.FindString(s) || !strings.Contains(events, "DTEND;VALUE=DATE:20220906") {
+		t.Errorf("homework events:\n%s", events)
+	}
+}
+
+func TestLessonFromSchedule(t *testing.T) {
+	if _, ok := LessonFromSchedule(dnevnik76.Schedule{Date: day(time.September, 5)}, "Физика", 45*time.Minute); ok {
+		t.Error("lesson without time")
+	}
+	l, ok := LessonFromSchedule(dnevnik76.Schedule{Date: day(time.September, 5).Add(8 * time.Hour)}, "Физика", 45*time.Minute)
+	if !ok || l.End.Sub(l.Start) != 45*time.Minute {
+		t.Errorf("lesson - %+v", l)
+	}
+}
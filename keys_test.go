@@ -0,0 +1,86 @@
+package dnevnik76
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	date := day(2022, time.September, 5)
+	cases := []struct {
+		key, want string
+	}{
+		{Mark{UserID: "08331111", SchoolID: 760215, SYear: 2022, CourseName: " Математика ", Date: date, Position: 1}.Key(),
+			"mark/08331111/760215/2022/lesson/Математика/2022-09-05/1"},
+		{Mark{UserID: "08331111", SchoolID: 760215, SYear: 2022, CourseID: 11, CourseName: "Математика", Quarter: 2}.Key(),
+			"mark/08331111/760215/2022/q2/11//0"},
+		{Mark{UserID: "08331111", SchoolID: 760215, SYear: 2022, CourseID: 11, Quarter: 1, Term: PeriodHalfYear}.Key(),
+			"mark/08331111/760215/2022/h1/11//0"},
+		{Mark{UserID: "08331111", SchoolID: 760215, SYear: 2022, CourseID: 11, Annual: true}.Key(),
+			"mark/08331111/760215/2022/year/11//0"},
+		{Homework{SchoolID: 760215, ClassID: 121, Date: date, CourseName: "Физика"}.Key(),
+			"homework/760215/121/2022-09-05/Физика/0"},
+		{Message{UserID: "08331111", ID: 7, Subject: "Собрание"}.Key(), "message/08331111/7"},
+	}
+	for _, c := range cases {
+		if c.key != c.want {
+			t.Errorf("key %q, want %q", c.key, c.want)
+		}
+	}
+
+	// grades, subject and homework are not part of the key
+	a := Mark{UserID: "u", CourseName: "Физика", Date: date, Grade: []int8{4}}
+	b := a
+	b.Grade, b.Subject, b.ID = []int8{5}, "Законы Ньютона", 42
+	if a.Key() != b.Key() {
+		t.Errorf("keys of corrected mark differ: %q, %q", a.Key(), b.Key())
+	}
+}
+
+func TestPositions(t *testing.T) {
+	date := day(2022, time.September, 5)
+	marks := []Mark{
+		{CourseName: "Математика", Date: date},
+		{CourseName: "Физика", Date: date},
+		{CourseName: " Математика", Date: date},
+		{CourseName: "Математика", Date: date, Position: 5},
+	}
+	pos := Positions(marks, Mark.Key, func(m *Mark) *int { return &m.Position })
+	for i, want := range []int{0, 0, 1, 5} {
+		if pos[i] != want {
+			t.Errorf("%d: position %d, want %d", i, pos[i], want)
+		}
+	}
+	if marks[3].Position != 5 {
+		t.Errorf("records changed - position %d", marks[3].Position)
+	}
+}
+
+func TestClient_GetMarksPositions(t *testing.T) {
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="marks"><div class="week"><div class="dayofweek">
+			<div class="weekday"><h3>Понедельник (5 сентября 2022 г.)</h3></div>
+			<table><tbody>
+			<tr><td>Математика</td><td></td><td class="col-mark"><span class="mark">5</span></td></tr>
+			<tr><td>Физика</td><td></td><td class="col-mark"><span class="mark">3</span></td></tr>
+			<tr><td>Математика</td><td></td><td class="col-mark"><span class="mark">4</span></td></tr>
+			</tbody></table></div></div></div>`)
+	}))
+	marks, err := cli.GetMarksFor("q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(marks) != 3 {
+		t.Fatalf("marks - %d, want 3", len(marks))
+	}
+	for i, want := range []int{0, 0, 1} {
+		if marks[i].Position != want {
+			t.Errorf("%s %v: position %d, want %d", marks[i].CourseName, marks[i].Grade, marks[i].Position, want)
+		}
+	}
+	if marks[0].Key() == marks[2].Key() {
+		t.Errorf("two lessons share key %q", marks[0].Key())
+	}
+}
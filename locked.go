@@ -0,0 +1,68 @@
+// Package dnevnik76 locked diary
+package dnevnik76
+
+import "sync"
+
+// Diary records of a user, implemented by *Client
+type Diary interface {
+	GetMarksCurrent() ([]Mark, error)
+	GetMarksFinal() ([]Mark, error)
+	GetHomework() ([]Homework, error)
+	GetMessagesCount() (unread int, total int, err error)
+	GetMessages() ([]Message, error)
+	GetTeachers() ([]Teacher, error)
+}
+
+// Locked diary makes one call at a time, so a client can be shared between goroutines.
+// *Client is not safe for concurrent use: GetHomework refreshes the session info the marks parsers read.
+type Locked struct {
+	mu sync.Mutex
+	d  Diary
+}
+
+// NewLocked diary calling d one at a time
+func NewLocked(d Diary) *Locked {
+	return &Locked{d: d}
+}
+
+// GetMarksCurrent of the locked diary
+func (l *Locked) GetMarksCurrent() ([]Mark, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetMarksCurrent()
+}
+
+// GetMarksFinal of the locked diary
+func (l *Locked) GetMarksFinal() ([]Mark, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetMarksFinal()
+}
+
+// GetHomework of the locked diary
+func (l *Locked) GetHomework() ([]Homework, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetHomework()
+}
+
+// GetMessagesCount of the locked diary
+func (l *Locked) GetMessagesCount() (int, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetMessagesCount()
+}
+
+// GetMessages of the locked diary
+func (l *Locked) GetMessages() ([]Message, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetMessages()
+}
+
+// GetTeachers of the locked diary
+func (l *Locked) GetTeachers() ([]Teacher, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.d.GetTeachers()
+}
@@ -7,8 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"regexp"
-	"strconv"
+	"sync"
 	"time"
 
 	"net/http"
@@ -17,10 +16,9 @@ import (
 
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/publicsuffix"
 
-	"github.com/bvp/russiantime"
+	"github.com/bvp/dnevnik76-api/parse"
 )
 
 const (
@@ -32,12 +30,15 @@ const (
 	urlMessages     = "https://my.dnevnik76.ru/messages/input"
 	urlTeachers     = "https://my.dnevnik76.ru/teachers/"
 
-	sLoadSubjectsS = "loadSubjects('/ajax/subj/"
-	sLoadSubjectsE = "', true)"
+	// periodsConcurrency limits parallel requests for periods date ranges
+	periodsConcurrency = 4
 )
 
 var (
 	u *url.URL
+
+	defaultSelectors = parse.DefaultProfile()
+
 	// DEBUG output
 	DEBUG bool
 )
@@ -52,12 +53,6 @@ func NewClient(login string, password string, regionID int64, schoolID int64, ht
 	ci := CurrentInfo{}
 	ci.RegionID = regionID
 	ci.SchoolID = schoolID
-	schools, _ := GetSchools(regionID)
-	for _, s := range schools {
-		if s.ID == schoolID {
-			ci.SchoolName = s.Name
-		}
-	}
 
 	cookie := &http.Cookie{
 		Name:   "items_perpage",
@@ -86,6 +81,7 @@ func NewClient(login string, password string, regionID int64, schoolID int64, ht
 		SchoolID:    schoolID,
 		http:        httpClient,
 		CurrentInfo: ci,
+		cache:       newCache(),
 	}
 
 	return cli
@@ -98,11 +94,12 @@ func (cli *Client) Login() (err error) {
 		return
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	token, issues, err := cli.selectors().ParseLoginToken(resp.Body)
+	resp.Body.Close()
+	cli.Token = token
+	if err = cli.checked(issues, err); err != nil {
 		return
 	}
-	cli.Token, _ = doc.Find(".login__form > input[name='csrfmiddlewaretoken']").First().Attr("value")
 
 	payload := url.Values{
 		"next":                {""}, // /marks/current/
@@ -131,46 +128,95 @@ func (cli *Client) Login() (err error) {
 	// 	return
 	// }
 
-	err = cli.getCurrentInfo()
-
+	if err = cli.getCurrentInfo(); err != nil {
+		return
+	}
+	if cli.CurrentInfo.SchoolName == "" {
+		cli.CurrentInfo.SchoolName = cli.schoolName()
+	}
 	return
 }
 
+// schoolName looked up in schools of the region, empty when the lookup fails
+func (cli *Client) schoolName() string {
+	schools, _ := cli.GetSchools(cli.CurrentInfo.RegionID)
+	for _, s := range schools {
+		if s.ID == cli.SchoolID {
+			return s.Name
+		}
+	}
+	return ""
+}
+
 // getCurrentInfo for session
 func (cli *Client) getCurrentInfo() (err error) {
-	resp, err := cli.http.Get(urlHomework)
+	body, err := cli.fetch(urlHomework)
 	if err != nil {
 		return
 	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	defer body.Close()
+
+	info, issues, err := cli.selectors().ParseCurrentInfo(body)
+	if err = cli.checked(issues, err); err != nil {
 		return
 	}
-
 	cli.CurrentInfo.SchoolID = cli.SchoolID
-	class, err := getClassName(doc.Find("#auth_info > #role").Text())
+	cli.CurrentInfo.Class = info.Class
+	cli.CurrentInfo.ClassID = info.ClassID
+	cli.CurrentInfo.EduYearStart = info.EduYearStart
+	cli.CurrentInfo.EduYearEnd = info.EduYearEnd
+
+	cli.ToJSON(cli.CurrentInfo)
+
+	return
+}
+
+// fetch page with client session, the caller closes the body
+func (cli *Client) fetch(url string) (body io.ReadCloser, err error) {
+	resp, err := cli.http.Get(url)
 	if err != nil {
 		return
 	}
-	cli.CurrentInfo.Class = class
+	return resp.Body, nil
+}
 
-	classIDText, _ := doc.Find("body").Attr("onload")
-	if classIDText != "" {
-		classIDText = strings.TrimSuffix(strings.TrimPrefix(classIDText, sLoadSubjectsS), sLoadSubjectsE)
-	}
-	classID, err := strconv.ParseInt(classIDText, 10, 32)
-	cli.CurrentInfo.ClassID = classID
+// info of the session for parsers
+func (cli *Client) info() CurrentInfo {
+	info := cli.CurrentInfo
+	info.SchoolID = cli.SchoolID
+	return info
+}
 
-	var eys, eye int64
-	eyr := strings.Split(strings.TrimSuffix(doc.Find("#eduyear > #curedy").Text(), " учебный год"), "-")
-	eys, _ = strconv.ParseInt(eyr[0], 10, 32)
-	eye, _ = strconv.ParseInt(eyr[1], 10, 32)
-	cli.CurrentInfo.EduYearStart = int(eys)
-	cli.CurrentInfo.EduYearEnd = int(eye)
+// selectors of the client profile
+func (cli *Client) selectors() *parse.SelectorProfile {
+	if cli.Selectors != nil {
+		return cli.Selectors
+	}
+	return &defaultSelectors
+}
 
-	cli.ToJSON(cli.CurrentInfo)
+// checked to report parse issues, they are errors in strict mode
+func (cli *Client) checked(issues []ParseIssue, err error) error {
+	if err != nil {
+		return err
+	}
+	if cli.Strict {
+		return parse.Check(issues)
+	}
+	if cli.OnParseIssue != nil {
+		for _, issue := range issues {
+			cli.OnParseIssue(issue)
+		}
+	}
+	return nil
+}
 
-	return
+// own to mark records as the client user ones
+func (cli *Client) own(marks []Mark) []Mark {
+	for i := range marks {
+		marks[i].UserID = cli.Username
+	}
+	return marks
 }
 
 // ToJSON convert object to json notation
@@ -188,7 +234,8 @@ func (cli *Client) PrintCookies() {
 	}
 }
 
-// SetCookie to set client cookie
+// SetCookie to set client cookie.
+// It changes the shared cookie jar, use ForYear to switch academic year.
 func (cli *Client) SetCookie(name, value string) {
 	cookie := &http.Cookie{
 		Name:  name,
@@ -203,146 +250,145 @@ func (cli *Client) SetCookie(name, value string) {
 	cli.getCurrentInfo()
 }
 
-// GetRegions to get client regions
-func GetRegions() (regions []Region, err error) {
-	resp, err := http.Get(fmt.Sprintf("%s/kladr/?login=true", urlAjax))
-	if err != nil {
-		return
+// Transport of the client requests
+func (cli *Client) Transport() http.RoundTripper {
+	if cli.http.Transport == nil {
+		return http.DefaultTransport
 	}
+	return cli.http.Transport
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return
-	}
+// SetTransport of the client requests, e.g. to record or replay them with the cassette package.
+// The cookie jar is kept.
+func (cli *Client) SetTransport(rt http.RoundTripper) {
+	hc := *cli.http
+	hc.Transport = rt
+	cli.http = &hc
+}
 
-	doc.Find("select > option").Each(func(i int, s *goquery.Selection) {
-		title := strings.TrimSpace(s.Text())
-		value, _ := s.Attr("value")
-		regionID, _ := strconv.ParseInt(value, 10, 64)
-		if regionID != 0 {
-			regions = append(regions, Region{ID: regionID, Name: title})
-		}
-	})
+// GetRegions to get client regions
+func GetRegions() (regions []Region, err error) {
+	regions, _, err = getRegions(http.DefaultClient, &defaultSelectors)
 	return
 }
 
 // GetSchools for selected region
 func GetSchools(region int64) (schools []School, err error) {
-	resp, err := http.Get(fmt.Sprintf("%s/school/%d/?login=true", urlAjax, region))
+	schools, _, err = getSchools(http.DefaultClient, &defaultSelectors, region)
+	return
+}
+
+// GetRegions through the client transport, so they are recorded and replayed with the cassette
+func (cli *Client) GetRegions() (regions []Region, err error) {
+	regions, issues, err := getRegions(cli.http, cli.selectors())
+	err = cli.checked(issues, err)
+	return
+}
+
+// GetSchools for selected region through the client transport
+func (cli *Client) GetSchools(region int64) (schools []School, err error) {
+	schools, issues, err := getSchools(cli.http, cli.selectors(), region)
+	err = cli.checked(issues, err)
+	return
+}
+
+func getRegions(hc *http.Client, p *parse.SelectorProfile) (regions []Region, issues []ParseIssue, err error) {
+	resp, err := hc.Get(fmt.Sprintf("%s/kladr/?login=true", urlAjax))
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
+	return p.ParseRegions(resp.Body)
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+func getSchools(hc *http.Client, p *parse.SelectorProfile, region int64) (schools []School, issues []ParseIssue, err error) {
+	resp, err := hc.Get(fmt.Sprintf("%s/school/%d/?login=true", urlAjax, region))
 	if err != nil {
 		return
 	}
-	doc.Find("select > optgroup").Each(func(i int, s *goquery.Selection) {
-		label, _ := s.Attr("label")
-		s.Find("option").Each(func(i int, s2 *goquery.Selection) {
-			title := strings.TrimSpace(s2.Text())
-			value, _ := s2.Attr("value")
-			schoolID, _ := strconv.ParseInt(value, 10, 64)
-			schools = append(schools, School{ID: schoolID, RegionID: region, Name: title, Type: label})
-		})
-	})
-	return
+	defer resp.Body.Close()
+	return p.ParseSchools(resp.Body, region)
 }
 
 func dateWithinRange(date, start, end time.Time) bool {
-	if date.After(start) && date.Before(end) {
-		return true
-	}
-	return false
+	return Lperiod{Start: start, End: end}.Contains(date)
 }
 
+// GetCurrentQuarter to get the term (quarter, half-year or trimester) for today
 func (cli *Client) GetCurrentQuarter() (result string) {
-	list := []string{"четверть", "полугодие"}
-	periods, _ := cli.GetMarksPeriods()
-	for _, p := range periods {
-		inRange := dateWithinRange(time.Now(), p.Start, p.End)
-		if inRange {
-			for _, s := range list {
-				if strings.Contains(p.Name, s) {
-					result = p.Period
-					break
-				}
-			}
-		}
+	periods, _ := cli.GetPeriods()
+	if p, ok := periods.Terms().Current(); ok {
+		result = p.Period
 	}
 	return
 }
 
 // GetCourses to get subjects
 func (cli *Client) GetCourses() (courses []Course, err error) {
-	resp, err := cli.http.Get(fmt.Sprintf("%s/subj/%d", urlAjax, cli.CurrentInfo.ClassID))
+	body, err := cli.fetch(fmt.Sprintf("%s/subj/%d", urlAjax, cli.CurrentInfo.ClassID))
 	if err != nil {
 		return
 	}
+	defer body.Close()
+	courses, issues, err := cli.selectors().ParseCourses(body)
+	return courses, cli.checked(issues, err)
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+// GetMarksPeriods to get marks periods, cached per academic year
+func (cli *Client) GetMarksPeriods() (periods []Lperiod, err error) {
+	year := cli.CurrentInfo.EduYearStart
+	if periods, ok := cli.cache.getPeriods(year); ok {
+		return periods, nil
+	}
+
+	body, err := cli.fetch(urlMarksCurrent)
 	if err != nil {
 		return
 	}
+	defer body.Close()
 
-	doc.Find("select > option").Each(func(i int, s *goquery.Selection) {
-		title := strings.TrimSpace(s.Text())
-		value, _ := s.Attr("value")
-		courseID, _ := strconv.ParseInt(value, 10, 64)
-		if courseID != 0 {
-			courses = append(courses, Course{ID: courseID, Name: title})
+	periods, issues, err := cli.selectors().ParsePeriods(body, cli.CurrentInfo)
+	if err = cli.checked(issues, err); err != nil {
+		return
+	}
+
+	errs := make([]error, len(periods))
+	sem := make(chan struct{}, periodsConcurrency)
+	var wg sync.WaitGroup
+	for i := range periods {
+		wg.Add(1)
+		go func(p *Lperiod, err *error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			p.Start, p.End, *err = cli.getPeriodRange(p.Period)
+		}(&periods[i], &errs[i])
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return nil, err
 		}
-	})
+	}
+
+	cli.cache.setPeriods(year, periods)
 	return
 }
 
-// GetMarksPeriods to get marks periods
-func (cli *Client) GetMarksPeriods() (periods []Lperiod, err error) {
-	resp, err := cli.http.Get(urlMarksCurrent)
+// getPeriodRange to read period dates from the page heading
+func (cli *Client) getPeriodRange(period string) (start, end time.Time, err error) {
+	resp, err := cli.http.Get(fmt.Sprintf("%s%s/note", urlMarksCurrent, period))
 	if err != nil {
 		return
 	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("period %s: %s", period, resp.Status)
 		return
 	}
-	doc.Find("#mark_range > optgroup").Each(func(i int, s *goquery.Selection) {
-		s.Find("option").Each(func(i int, s2 *goquery.Selection) {
-			title := strings.TrimSpace(s2.Text())
-			value, _ := s2.Attr("value")
-
-			var resp *http.Response
-			resp, err = cli.http.Get(fmt.Sprintf("%s%s/note", urlMarksCurrent, value))
-			if err != nil {
-				return
-			}
-
-			doc, err := goquery.NewDocumentFromReader(resp.Body)
-			if err != nil {
-				return
-			}
-			re := regexp.MustCompile(`(?P<start>(\d{1,2}\s[\p{L}]+\s\d{4}\sг\.)) по (?P<end>(\d{1,2}\s[\p{L}]+\s\d{4}\sг\.))`)
-			n1 := re.SubexpNames()
-			result := re.FindStringSubmatch(doc.Find("#content > h3").First().Text())
-			m := map[string]string{}
-			for i, n := range result {
-				m[n1[i]] = n
-			}
-
-			period := Lperiod{
-				SchoolID: cli.CurrentInfo.SchoolID,
-				SYear:    cli.CurrentInfo.EduYearStart,
-				EYear:    cli.CurrentInfo.EduYearEnd,
-				Name:     title,
-				Period:   value,
-				Start:    russiantime.ParseDateString(m["start"]),
-				End:      russiantime.ParseDateString(m["end"]),
-			}
-			periods = append(periods, period)
-		})
-	})
-	return
+
+	start, end, issues, err := cli.selectors().ParsePeriodRange(resp.Body)
+	return start, end, cli.checked(issues, err)
 }
 
 // GetMarksCurrent to get marks for current month
@@ -363,310 +409,118 @@ func (cli *Client) GetMarksForWithType(p string, t MarksListType) (marks []Mark,
 	} else {
 		sp = fmt.Sprintf("%s/", t.String())
 	}
-	var resp *http.Response
-	resp, err = cli.http.Get(fmt.Sprintf("%s%s", urlMarksCurrent, sp))
+	body, err := cli.fetch(fmt.Sprintf("%s%s", urlMarksCurrent, sp))
 	if err != nil {
 		return
 	}
+	defer body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return
-	}
-	rpt := regexp.MustCompile(`(\r\n)+|\r+|\n+|\t+|\s+`)
-	log.Printf("page title - %s", rpt.ReplaceAllString(doc.Find("#content > h3").First().Text(), " "))
+	var issues []ParseIssue
 	switch t {
 	case Note:
-		doc.Find("#marks > div.week").Each(func(i int, s *goquery.Selection) {
-			s.Find("div.dayofweek").Each(func(j int, s2 *goquery.Selection) {
-				title := strings.TrimSpace(s2.Find("div.weekday > h3").First().Text())
-				table := s2.Find("table")
-				table.Find("tbody > tr").Each(func(k int, tr *goquery.Selection) {
-					mark := Mark{}
-					mark.SYear = cli.CurrentInfo.EduYearStart
-					mark.EYear = cli.CurrentInfo.EduYearEnd
-					mark.UserID = cli.Username
-					mark.SchoolID = cli.SchoolID
-					pd := strings.Split(strings.TrimRight(title, ")"), " (")
-					mark.DayOfWeek = pd[0]
-					mark.Date = russiantime.ParseDateString(pd[1])
-
-					course := tr.Find("td:nth-child(1)").First().Text()
-					mark.CourseName = course
-					pt, _ := tr.Attr("title")
-					lessonTitle := strings.TrimSpace(strings.TrimLeft(pt, "Тема: "))
-					mark.Subject = lessonTitle
-					hw := tr.Find("td:nth-child(2)").First().Text()
-					mark.HomeWork = strings.TrimSpace(hw)
-					tr.Find("td.col-mark > span.mark").Each(func(l int, m *goquery.Selection) {
-						pm, _ := strconv.ParseInt(m.Text(), 10, 32)
-						mark.Grade = append(mark.Grade, int8(pm))
-					})
-					marks = append(marks, mark)
-				})
-			})
-		})
+		marks, issues, err = cli.selectors().ParseMarksNote(body, cli.info())
 	case List:
-		// TODO: fill DayOfWeek, Subject, HomeWork
-		// URL: https://regex101.com/r/CwEys5/4
-		doc.Find("#marks > #mark-row").Each(func(i int, s *goquery.Selection) {
-			courseName := s.Find("div.mark-label").Text()
-			s.Find("span.mark").Each(func(j int, sj *goquery.Selection) {
-				if !sj.HasClass("avg") {
-					mark := Mark{}
-					mark.SYear = cli.CurrentInfo.EduYearStart
-					mark.EYear = cli.CurrentInfo.EduYearEnd
-					mark.CourseName = courseName
-					el := sj.Find("a").First()
-					onClick, _ := el.Attr("onclick")
-					reg := regexp.MustCompile(`(showMarkInfo\(')(\d{1,2}\s\p{Cyrillic}*\s\d{4}\sг\.\s\(\p{Cyrillic}*\))?([^"]*)`)
-					d := reg.ReplaceAllString(onClick, "${2}")
-					pm, _ := strconv.ParseInt(el.Text(), 10, 32)
-					mark.Date = russiantime.ParseDateString(d)
-					mark.Grade = append(mark.Grade, int8(pm))
-					marks = append(marks, mark)
-				}
-			})
-		})
+		marks, issues, err = cli.selectors().ParseMarksList(body, cli.info())
 	case Date:
 		log.Println("Not implemented right now")
 	default:
 		//
 	}
 
-	return
+	return cli.own(marks), cli.checked(issues, err)
 }
 
-// GetMarksFinal to get final marks
-func (cli *Client) GetMarksFinal() (marks []Mark, err error) {
-	resp, err := cli.http.Get(urlMarksFinal)
+// GetMarksAverages to get course averages the site shows in list view for period
+func (cli *Client) GetMarksAverages(p string) (avgs []CourseAverage, err error) {
+	sp := fmt.Sprintf("%s/", List.String())
+	if p != "" {
+		sp = fmt.Sprintf("%s/%s/", p, List.String())
+	}
+	body, err := cli.fetch(fmt.Sprintf("%s%s", urlMarksCurrent, sp))
 	if err != nil {
 		return
 	}
+	defer body.Close()
+	avgs, issues, err := cli.selectors().ParseMarksAverages(body, p)
+	return avgs, cli.checked(issues, err)
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+// GetMarksFinal to get final marks
+func (cli *Client) GetMarksFinal() (marks []Mark, err error) {
+	body, err := cli.fetch(urlMarksFinal)
 	if err != nil {
 		return
 	}
-	courses, _ := cli.GetCourses()
-	doc.Find("#marks > #wrap-col > #wrap-marks > div > #mark-row").Each(func(i int, s *goquery.Selection) {
-		courseID, _ := s.Attr("name")
-
-		s.Find(".mark").Each(func(j int, sj *goquery.Selection) {
-			mark := Mark{}
-			mark.SYear = cli.CurrentInfo.EduYearStart
-			mark.EYear = cli.CurrentInfo.EduYearEnd
-			mark.UserID = cli.Username
-			mark.SchoolID = cli.SchoolID
-			mark.CourseID, _ = strconv.ParseInt(courseID, 10, 32)
-			for _, c := range courses {
-				if c.ID == mark.CourseID {
-					mark.CourseName = c.Name
-					break
-				}
-			}
-			data := func() (period string, fmark string) {
-				el := sj.Find("a").First()
-				onClick, _ := el.Attr("onclick")
-				fmark = el.Text()
-				reg := regexp.MustCompile(`(showMarkItogInfo\(')(\d\s\p{Cyrillic}*)?([^"]*)`)
-				period = reg.ReplaceAllString(onClick, "${2}")
-				return
-			}
-			if sj.HasClass("itg-q") {
-				period, fmark := data()
-				regd := regexp.MustCompile("[0-9]+")
-				digs := regd.FindAllString(period, -1)
-				if len(digs) > 0 {
-					mp, _ := strconv.ParseInt(digs[0], 10, 32)
-					mark.Quarter = int(mp)
-				}
-				pm, _ := strconv.ParseInt(fmark, 10, 32)
-				mark.Grade = append(mark.Grade, int8(pm))
-				marks = append(marks, mark)
-			} else if sj.HasClass("itg-y") {
-				_, fmark := data()
-				mark.Annual = true
-				pm, _ := strconv.ParseInt(fmark, 10, 32)
-				mark.Grade = append(mark.Grade, int8(pm))
-				marks = append(marks, mark)
-			}
-		})
-	})
+	defer body.Close()
 
-	return
+	courses, _ := cli.GetCourses()
+	marks, issues, err := cli.selectors().ParseMarksFinal(body, cli.info(), courses)
+	return cli.own(marks), cli.checked(issues, err)
 }
 
 // GetMessagesCount get current user messages count
 func (cli *Client) GetMessagesCount() (unread int, total int, err error) {
-	resp, err := cli.http.Get(fmt.Sprintf("%s/messages_count/", urlAjax))
-	if err != nil {
-		return
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := cli.fetch(fmt.Sprintf("%s/messages_count/", urlAjax))
 	if err != nil {
 		return
 	}
-	respMap := make(map[string]int)
-	json.Unmarshal([]byte(body), &respMap)
-
-	return respMap["unread_messages"], respMap["all_messages"], nil
+	defer body.Close()
+	unread, total, issues, err := parse.ParseMessagesCount(body)
+	return unread, total, cli.checked(issues, err)
 }
 
 // GetMessages list for current user
 func (cli *Client) GetMessages() (messages []Message, err error) {
-	resp, err := cli.http.Get(urlMessages)
-	if err != nil {
-		return
-	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	body, err := cli.fetch(urlMessages)
 	if err != nil {
 		return
 	}
+	defer body.Close()
 
-	pagesFlag := doc.Find("#content > div.pager > span.page_remark").Text()
-	if pagesFlag != "" {
-		pages := doc.Find("#content > div.pager > span.page")
-		totalPages, ep := strconv.ParseInt(pages.Eq(pages.Size()-2).Text(), 10, 32)
-		if ep != nil {
-			err = ep
-			return
-		}
-		if DEBUG {
-			log.Printf("pages - '%s'\n", pages.Text())
-			pages.Each(func(i int, p *goquery.Selection) {
-				log.Printf("page - %s\n", p.Text())
-			})
-			log.Printf("total pages - %d\n", totalPages)
-		}
+	messages, issues, err := cli.selectors().ParseMessages(body)
+	for i := range messages {
+		messages[i].UserID = cli.Username
 	}
-
-	doc.Find("#content > form > table.list > tbody > tr").Each(func(i int, s *goquery.Selection) {
-		message := Message{}
-		message.UserID = cli.Username
-		msgID, _ := s.Find("td:nth-child(1) > input").Attr("value")
-		message.ID, _ = strconv.ParseInt(msgID, 10, 64)
-		title := s.Find("td:nth-child(2) > a")
-		message.Subject = strings.TrimSpace(title.Text())
-		if title.HasClass("unread") {
-			message.IsUnread = true
-		}
-		from := s.Find("td:nth-child(3)").Text()
-		message.From = from
-		date := s.Find("td:nth-child(4)").Text()
-		message.Date = russiantime.ParseDateString(date)
-		messages = append(messages, message)
-	})
-	return
+	return messages, cli.checked(issues, err)
 }
 
 // GetMessage by id
 func (cli *Client) GetMessage(msgID int64) (m Message, err error) {
 	m.ID = msgID
-	resp, err := cli.http.Get(fmt.Sprintf("%s/%d/", urlMessages, msgID))
+	body, err := cli.fetch(fmt.Sprintf("%s/%d/", urlMessages, msgID))
 	if err != nil {
 		return
 	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return
-	}
-
-	msgDate := strings.TrimPrefix(doc.Find("#msgview > div.msg-meta > div.msg-props > div:nth-child(1)").First().Text(), "Дата: ")
-	m.Date = russiantime.ParseDateString(msgDate)
-	msgFrom := doc.Find("#msgview > div.msg-meta > div.msg-props > div:nth-child(2) > a:nth-child(2)").First().Text()
-	m.From = msgFrom
-	msgText := doc.Find("#msgview > div.msg-text").First()
-	m.Body = msgText.Text()
-
-	return
-}
-
-func getClassName(s string) (class string, err error) {
-	re_inside_whtsp := regexp.MustCompile(`[\s\p{Zs}]{2,}`)
-	final := re_inside_whtsp.ReplaceAllString(strings.TrimSpace(s), " ")
-	class = strings.TrimLeft(strings.TrimRight(final, ")"), "Учащийся (")
-	return
+	defer body.Close()
+	m, issues, err := cli.selectors().ParseMessage(body, msgID)
+	m.UserID = cli.Username
+	return m, cli.checked(issues, err)
 }
 
 // GetHomework to get user homework
 func (cli *Client) GetHomework() (hws []Homework, err error) {
-	resp, err := cli.http.Get(urlHomework)
-	if err != nil {
-		return
-	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	body, err := cli.fetch(urlHomework)
 	if err != nil {
 		return
 	}
+	defer body.Close()
 
 	err = cli.getCurrentInfo()
 	if err != nil {
 		return
 	}
 
-	classIDText, _ := doc.Find("body").Attr("onload")
-	if classIDText != "" {
-		classIDText = strings.TrimRight(strings.TrimPrefix(classIDText, sLoadSubjectsS), sLoadSubjectsE)
-	}
-	classID, err := strconv.ParseInt(classIDText, 10, 64)
-	hwPagesFlag := doc.Find("#homework_list > div.pager > span.page_remark").Text()
-	if hwPagesFlag != "" {
-		pages := doc.Find("#homework_list > div.pager > span.page")
-		if DEBUG {
-			log.Printf("total pages - %s\n", pages.Eq(pages.Size()-2).Text())
-		}
-	}
-
-	doc.Find("#homework_list > table.list > tbody > tr").Each(func(i int, s *goquery.Selection) {
-		h := Homework{}
-		h.SchoolID = cli.SchoolID
-		h.ClassID = classID
-		date := s.Find("td:nth-child(1)").Text()
-		h.Date = russiantime.ParseDateString(date)
-		wday := s.Find("td:nth-child(2)").Text()
-		h.DayOfWeek = wday
-		course := s.Find("td:nth-child(3) > a").Text()
-		h.CourseName = course
-		hw := s.Find("td:nth-child(4)").Text()
-		h.Homework = strings.TrimSpace(hw)
-		subject := s.Find("td:nth-child(5)").Text()
-		h.Subject = strings.TrimSpace(subject)
-
-		hws = append(hws, h)
-	})
-
-	return
+	hws, issues, err := cli.selectors().ParseHomework(body, cli.info())
+	return hws, cli.checked(issues, err)
 }
 
 // GetTeachers to get class teachers
 func (cli *Client) GetTeachers() (teachers []Teacher, err error) {
-	resp, err := cli.http.Get(urlTeachers)
-	if err != nil {
-		return
-	}
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	body, err := cli.fetch(urlTeachers)
 	if err != nil {
 		return
 	}
-
-	doc.Find("#content > table.list > tbody > tr").Each(func(i int, t *goquery.Selection) {
-		teacher := Teacher{}
-		teacher.SchoolID = cli.SchoolID
-		el := t.Find("td.action_links > a.mailto")
-		href, _ := el.Attr("href")
-		teacher.UserID = strings.TrimSuffix(
-			strings.TrimPrefix(href, "/messages/new/?to="), fmt.Sprintf("@%d", cli.SchoolID))
-		fio := t.Find("td:nth-child(2)").Text()
-		teacher.FullName = fio
-		courseName := strings.TrimSpace(t.Find("td:nth-child(3) > b").Text())
-		if courseName == "" {
-			courseName = strings.TrimSpace(t.Find("td:nth-child(3)").Text())
-		}
-		teacher.CourseName = courseName
-		teachers = append(teachers, teacher)
-	})
-
-	return
+	defer body.Close()
+	teachers, issues, err := cli.selectors().ParseTeachers(body, cli.SchoolID)
+	return teachers, cli.checked(issues, err)
 }
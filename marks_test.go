@@ -0,0 +1,61 @@
+package dnevnik76
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestClient_GetMarksAverages(t *testing.T) {
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/marks/current/q1/list/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<div id="marks">
+			<div id="mark-row"><div class="mark-label">Алгебра</div><span class="mark"><a>5</a></span><span class="mark avg">4,67</span></div>
+			<div id="mark-row"><div class="mark-label">Физика</div><span class="mark avg"></span></div></div>`)
+	}))
+	avgs, err := cli.GetMarksAverages("q1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avgs) != 1 || avgs[0].CourseName != "Алгебра" || avgs[0].Average != 4.67 || avgs[0].Period != "q1" {
+		t.Errorf("averages - %+v", avgs)
+	}
+}
+
+func TestClient_Strict(t *testing.T) {
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="marks"><div class="week"><div class="dayofweek">
+			<div class="weekday"><h3>Понедельник (5 сентября 2022 г.)</h3></div>
+			<table><tbody><tr><td>Физика</td><td></td><td class="col-mark"><span class="mark">5</span><span class="mark">?</span></td></tr></tbody></table>
+			</div></div></div>`)
+	}))
+	var reported []ParseIssue
+	cli.OnParseIssue = func(is ParseIssue) { reported = append(reported, is) }
+	marks, err := cli.GetMarksFor("q1")
+	if err != nil || len(marks) != 1 || len(reported) != 1 || reported[0].Raw != "?" {
+		t.Fatalf("lenient %v - %v (%v)", marks, reported, err)
+	}
+
+	cli.Strict = true
+	marks, err = cli.GetMarksFor("q1")
+	if err == nil || len(marks) != 1 || marks[0].UserID != cli.Username || len(reported) != 1 {
+		t.Errorf("strict %v - %v", marks, err)
+	}
+}
+
+func TestClient_StrictCurrentInfo(t *testing.T) {
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<body onload="loadSubjects('/ajax/subj/121', true)"><div id="auth_info"><span id="role">Учащийся (7А)</span></div></body>`)
+	}))
+	cli.Strict = true
+	cli.CurrentInfo = CurrentInfo{Class: "6А", ClassID: 7, EduYearStart: 2021}
+	if err := cli.getCurrentInfo(); err == nil {
+		t.Fatal("no error for page without school year")
+	}
+	if cli.CurrentInfo.ClassID != 7 || cli.CurrentInfo.Class != "6А" || cli.CurrentInfo.EduYearStart != 2021 {
+		t.Errorf("partial info kept - %+v", cli.CurrentInfo)
+	}
+}
@@ -0,0 +1,78 @@
+// Package model natural keys
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key of mark, the same for the same mark between scrapes:
+//
+//	mark/<user>/<school>/<start year>/<period>/<course>/<date>/<position>
+//
+// Period is q<N>, h<N> or t<N> for quarter, half-year and trimester marks,
+// year for annual ones and lesson for regular ones.
+// Course is the course id when known, the trimmed course name otherwise.
+// Date is 2006-01-02 or empty for final marks.
+func (m Mark) Key() string {
+	period := "lesson"
+	if m.Annual {
+		period = "year"
+	} else if m.Quarter > 0 {
+		term := "q"
+		switch m.Term {
+		case PeriodHalfYear:
+			term = "h"
+		case PeriodTrimester:
+			term = "t"
+		}
+		period = fmt.Sprintf("%s%d", term, m.Quarter)
+	}
+	course := strings.TrimSpace(m.CourseName)
+	if m.CourseID != 0 {
+		course = strconv.FormatInt(m.CourseID, 10)
+	}
+	return fmt.Sprintf("mark/%s/%d/%d/%s/%s/%s/%d",
+		m.UserID, m.SchoolID, m.SYear, period, course, keyDate(m.Date), m.Position)
+}
+
+// Key of homework, the same for the same lesson between scrapes:
+//
+//	homework/<school>/<class>/<date>/<course>/<position>
+func (h Homework) Key() string {
+	return fmt.Sprintf("homework/%d/%d/%s/%s/%d",
+		h.SchoolID, h.ClassID, keyDate(h.Date), strings.TrimSpace(h.CourseName), h.Position)
+}
+
+// Key of message:
+//
+//	message/<user>/<id>
+func (m Message) Key() string {
+	return fmt.Sprintf("message/%s/%d", m.UserID, m.ID)
+}
+
+// Positions of records for their keys: the set position of a record or, when zero, its occurrence
+// among the records with the same key, so several lessons of a course on a day stay distinct
+func Positions[T any](records []T, key func(T) string, position func(*T) *int) []int {
+	pos := make([]int, len(records))
+	seen := map[string]int{}
+	for i, r := range records {
+		p := position(&r)
+		pos[i], *p = *p, 0
+		k := key(r)
+		if pos[i] == 0 {
+			pos[i] = seen[k]
+		}
+		seen[k]++
+	}
+	return pos
+}
+
+func keyDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
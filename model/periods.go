@@ -0,0 +1,209 @@
+// Package model periods
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// PeriodKind of academic period
+type PeriodKind int
+
+const (
+	// PeriodUnknown is a period that could not be classified
+	PeriodUnknown PeriodKind = iota
+	// PeriodQuarter is a quarter (четверть)
+	PeriodQuarter
+	// PeriodHalfYear is a half-year (полугодие)
+	PeriodHalfYear
+	// PeriodTrimester is a trimester (триместр)
+	PeriodTrimester
+	// PeriodMonth is a calendar month
+	PeriodMonth
+	// PeriodYear is a whole academic year
+	PeriodYear
+)
+
+func (k PeriodKind) String() string {
+	return [...]string{"unknown", "quarter", "half-year", "trimester", "month", "year"}[k]
+}
+
+// IsTerm reports whether kind is a term the final marks are given for
+func (k PeriodKind) IsTerm() bool {
+	return k == PeriodQuarter || k == PeriodHalfYear || k == PeriodTrimester
+}
+
+var monthNames = []string{
+	"январь", "февраль", "март", "апрель", "май", "июнь",
+	"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь",
+}
+
+// Kind of period guessed from its name and value
+func (p Lperiod) Kind() PeriodKind {
+	name := strings.ToLower(strings.TrimSpace(p.Name))
+	switch {
+	case strings.Contains(name, "четверт"):
+		return PeriodQuarter
+	case strings.Contains(name, "полугод"):
+		return PeriodHalfYear
+	case strings.Contains(name, "триместр"):
+		return PeriodTrimester
+	case strings.HasPrefix(p.Period, "month"):
+		return PeriodMonth
+	case strings.Contains(name, "год"):
+		return PeriodYear
+	}
+	for _, m := range monthNames {
+		if strings.HasPrefix(name, m) {
+			return PeriodMonth
+		}
+	}
+	return PeriodUnknown
+}
+
+// Contains reports whether date falls within period, both boundary days included
+func (p Lperiod) Contains(date time.Time) bool {
+	return dateWithinRange(date, p.Start, p.End)
+}
+
+// Gap between two consecutive periods, e.g. holidays
+type Gap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	After Lperiod   `json:"after"`
+	Until Lperiod   `json:"until"`
+}
+
+// Contains reports whether date falls within gap, both boundary days included
+func (g Gap) Contains(date time.Time) bool {
+	return dateWithinRange(date, g.Start, g.End)
+}
+
+// Periods is a list of academic periods ordered by start date
+type Periods []Lperiod
+
+// NewPeriods to build ordered periods from the list
+func NewPeriods(list []Lperiod) Periods {
+	ps := make(Periods, len(list))
+	copy(ps, list)
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Start.Equal(ps[j].Start) {
+			return ps[i].End.Before(ps[j].End)
+		}
+		return ps[i].Start.Before(ps[j].Start)
+	})
+	return ps
+}
+
+// OfKind to get only periods of the given kinds
+func (ps Periods) OfKind(kinds ...PeriodKind) (result Periods) {
+	for _, p := range ps {
+		for _, k := range kinds {
+			if p.Kind() == k {
+				result = append(result, p)
+				break
+			}
+		}
+	}
+	return
+}
+
+// Terms to get quarters, half-years and trimesters only
+func (ps Periods) Terms() Periods {
+	return ps.OfKind(PeriodQuarter, PeriodHalfYear, PeriodTrimester)
+}
+
+// FinalTerms to get the terms of one kind the final marks are given for,
+// quarters when the site lists them along with half-years
+func (ps Periods) FinalTerms() Periods {
+	for _, k := range []PeriodKind{PeriodQuarter, PeriodTrimester, PeriodHalfYear} {
+		if terms := ps.OfKind(k); len(terms) > 0 {
+			return terms
+		}
+	}
+	return nil
+}
+
+// PeriodAt to get the shortest period containing date,
+// so a quarter is preferred to the half-year it is part of
+func (ps Periods) PeriodAt(date time.Time) (p Lperiod, ok bool) {
+	for _, c := range ps {
+		if c.Contains(date) && (!ok || c.End.Sub(c.Start) < p.End.Sub(p.Start)) {
+			p, ok = c, true
+		}
+	}
+	return
+}
+
+// Current period for today
+func (ps Periods) Current() (Lperiod, bool) {
+	return ps.PeriodAt(time.Now())
+}
+
+// Next period starting after today
+func (ps Periods) Next() (Lperiod, bool) {
+	return ps.NextAfter(time.Now())
+}
+
+// NextAfter to get the first period starting after date
+func (ps Periods) NextAfter(date time.Time) (p Lperiod, ok bool) {
+	for _, p := range ps {
+		if dayOf(p.Start).After(dayIn(date, p.Start.Location())) {
+			return p, true
+		}
+	}
+	return
+}
+
+// Previous period ended before today
+func (ps Periods) Previous() (Lperiod, bool) {
+	return ps.PreviousBefore(time.Now())
+}
+
+// PreviousBefore to get the last period ended before date
+func (ps Periods) PreviousBefore(date time.Time) (p Lperiod, ok bool) {
+	for i := len(ps) - 1; i >= 0; i-- {
+		if dayOf(ps[i].End).Before(dayIn(date, ps[i].End.Location())) {
+			return ps[i], true
+		}
+	}
+	return
+}
+
+// Gaps between consecutive periods, e.g. holidays between quarters
+func (ps Periods) Gaps() (gaps []Gap) {
+	for i := 1; i < len(ps); i++ {
+		prev, next := ps[i-1], ps[i]
+		start := dayOf(prev.End).AddDate(0, 0, 1)
+		end := dayOf(next.Start).AddDate(0, 0, -1)
+		if end.Before(start) {
+			continue
+		}
+		gaps = append(gaps, Gap{Start: start, End: end, After: prev, Until: next})
+	}
+	return
+}
+
+// GapAt to get the gap containing date
+func (ps Periods) GapAt(date time.Time) (g Gap, ok bool) {
+	for _, g := range ps.Gaps() {
+		if g.Contains(date) {
+			return g, true
+		}
+	}
+	return
+}
+
+func dateWithinRange(date, start, end time.Time) bool {
+	day := dayIn(date, start.Location())
+	return !day.Before(dayOf(start)) && !day.After(dayOf(end))
+}
+
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func dayIn(t time.Time, loc *time.Location) time.Time {
+	return dayOf(t.In(loc))
+}
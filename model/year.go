@@ -0,0 +1,22 @@
+// Package model academic years
+package model
+
+import "fmt"
+
+// EduYear is an academic year identified by the calendar year it starts in
+type EduYear int
+
+// CurrentEduYear is the academic year the site considers current
+const CurrentEduYear EduYear = 0
+
+func (y EduYear) String() string {
+	if y == CurrentEduYear {
+		return "current"
+	}
+	return fmt.Sprintf("%d-%d", int(y), int(y)+1)
+}
+
+// EduYear of the current info
+func (ci CurrentInfo) EduYear() EduYear {
+	return EduYear(ci.EduYearStart)
+}
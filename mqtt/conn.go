@@ -0,0 +1,307 @@
+// Package mqtt publishes diary state to MQTT for home-automation dashboards
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPingreq    = 0xc0
+	packetPingresp   = 0xd0
+	packetDisconnect = 0xe0
+
+	flagRetain = 0x01
+)
+
+// ErrNoPingResponse is reported when the broker does not answer a ping within Timeout
+var ErrNoPingResponse = errors.New("mqtt: no reply to ping")
+
+// Conn is a minimal MQTT 3.1.1 connection able to publish with QoS 0.
+// It pings the broker each KeepAlive and reconnects when the connection breaks,
+// publishes fail until it is back.
+type Conn struct {
+	addr string
+	opts Options
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	done      chan struct{}
+}
+
+// Options of connection
+type Options struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	Timeout   time.Duration
+	// OnError is called when the connection breaks and when reconnecting fails
+	OnError func(error)
+}
+
+// Dial broker at addr (host:port) and connect
+func Dial(addr string, opts Options) (*Conn, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = time.Minute
+	}
+	nc, w, err := dial(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{addr: addr, opts: opts, conn: nc, w: w, closed: make(chan struct{}), done: make(chan struct{})}
+	go c.keepAlive()
+	return c, nil
+}
+
+// dial and send CONNECT waiting for CONNACK
+func dial(addr string, opts Options) (nc net.Conn, w *bufio.Writer, err error) {
+	if nc, err = net.DialTimeout("tcp", addr, opts.Timeout); err != nil {
+		return
+	}
+	w = bufio.NewWriter(nc)
+	if err = connect(nc, w, opts); err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
+	return
+}
+
+func connect(nc net.Conn, w *bufio.Writer, opts Options) error {
+	var flags byte = 0x02 // clean session
+	var payload []byte
+	payload = appendString(payload, opts.ClientID)
+	if opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, opts.Username)
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+		payload = appendString(payload, opts.Password)
+	}
+	var vh []byte
+	vh = appendString(vh, "MQTT")
+	vh = append(vh, 4, flags)
+	vh = binary.BigEndian.AppendUint16(vh, uint16(opts.KeepAlive/time.Second))
+
+	nc.SetDeadline(time.Now().Add(opts.Timeout))
+	defer nc.SetDeadline(time.Time{})
+	if err := writePacket(w, packetConnect, append(vh, payload...)); err != nil {
+		return err
+	}
+
+	var ack [4]byte
+	if _, err := io.ReadFull(nc, ack[:]); err != nil {
+		return err
+	}
+	if ack[0] != packetConnack || ack[1] != 2 {
+		return errors.New("mqtt: unexpected reply to connect")
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("mqtt: connection refused, code %d", ack[3])
+	}
+	return nil
+}
+
+// Publish payload to topic with QoS 0
+func (c *Conn) Publish(topic string, payload []byte, retain bool) error {
+	header := byte(packetPublish)
+	if retain {
+		header |= flagRetain
+	}
+	return c.write(header, append(appendString(nil, topic), payload...))
+}
+
+// Close to disconnect from broker
+func (c *Conn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.write(packetDisconnect, nil)
+		c.mu.Lock()
+		err = c.conn.Close()
+		c.mu.Unlock()
+		<-c.done
+	})
+	return
+}
+
+// keepAlive pings the broker until Close, a broken connection is replaced with a new one
+func (c *Conn) keepAlive() {
+	defer close(c.done)
+	for {
+		c.mu.Lock()
+		nc := c.conn
+		c.mu.Unlock()
+		pong := make(chan struct{}, 1)
+		broken := make(chan error, 1)
+		go read(nc, pong, broken)
+
+		err := c.ping(pong, broken)
+		nc.Close()
+		if err == nil {
+			return
+		}
+		c.report(err)
+		if !c.reconnect() {
+			return
+		}
+	}
+}
+
+// ping each KeepAlive until the connection breaks, nil when closed
+func (c *Conn) ping(pong <-chan struct{}, broken <-chan error) error {
+	t := time.NewTicker(c.opts.KeepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return nil
+		case err := <-broken:
+			return err
+		case <-t.C:
+		}
+		if err := c.write(packetPingreq, nil); err != nil {
+			return err
+		}
+		select {
+		case <-c.closed:
+			return nil
+		case err := <-broken:
+			return err
+		case <-pong:
+		case <-time.After(c.opts.Timeout):
+			return ErrNoPingResponse
+		}
+	}
+}
+
+// reconnect with growing delay up to KeepAlive, false when closed meanwhile
+func (c *Conn) reconnect() bool {
+	delay := time.Second
+	if delay > c.opts.KeepAlive {
+		delay = c.opts.KeepAlive
+	}
+	for {
+		select {
+		case <-c.closed:
+			return false
+		case <-time.After(delay):
+		}
+		nc, w, err := dial(c.addr, c.opts)
+		if err != nil {
+			c.report(err)
+			if delay *= 2; delay > c.opts.KeepAlive {
+				delay = c.opts.KeepAlive
+			}
+			continue
+		}
+		c.mu.Lock()
+		select {
+		case <-c.closed:
+			c.mu.Unlock()
+			nc.Close()
+			return false
+		default:
+		}
+		c.conn, c.w = nc, w
+		c.mu.Unlock()
+		return true
+	}
+}
+
+func (c *Conn) report(err error) {
+	if c.opts.OnError != nil {
+		c.opts.OnError(err)
+	}
+}
+
+// read packets of connection, PINGRESP goes to pong and the error ending the connection to broken
+func read(nc net.Conn, pong chan<- struct{}, broken chan<- error) {
+	r := bufio.NewReader(nc)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			broken <- err
+			return
+		}
+		n, mul := 0, 1
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				broken <- err
+				return
+			}
+			n += int(b&0x7f) * mul
+			mul *= 128
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		if _, err = r.Discard(n); err != nil {
+			broken <- err
+			return
+		}
+		if header&0xf0 == packetPingresp {
+			select {
+			case pong <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// write packet within Timeout, so a stalled broker does not block publishes, pings and Close.
+// The connection is closed on failure to be replaced by keepAlive.
+func (c *Conn) write(header byte, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	err := writePacket(c.w, header, body)
+	if err != nil {
+		c.conn.Close()
+	}
+	return err
+}
+
+func writePacket(w *bufio.Writer, header byte, body []byte) error {
+	w.WriteByte(header)
+	w.Write(appendLength(nil, len(body)))
+	w.Write(body)
+	return w.Flush()
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// appendLength in MQTT variable length encoding
+func appendLength(b []byte, n int) []byte {
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			return b
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// pingBroker answers pings of every client, sends the connections to conns and publishes to topics
+func pingBroker(t *testing.T) (addr string, conns <-chan net.Conn, topics <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	cs, ts := make(chan net.Conn, 10), make(chan string, 10)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					header, err := r.ReadByte()
+					if err != nil {
+						return
+					}
+					n, _ := r.ReadByte()
+					body := make([]byte, n)
+					io.ReadFull(r, body)
+					switch header & 0xf0 {
+					case packetConnect:
+						c.Write([]byte{packetConnack, 2, 0, 0})
+						cs <- c
+					case packetPingreq:
+						c.Write([]byte{packetPingresp, 0})
+					case packetPublish:
+						tl := binary.BigEndian.Uint16(body)
+						ts <- string(body[2 : 2+tl])
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), cs, ts
+}
+
+func TestConn_Reconnect(t *testing.T) {
+	addr, conns, topics := pingBroker(t)
+	errs := make(chan error, 10)
+	conn, err := Dial(addr, Options{ClientID: "dnevnik76", KeepAlive: 20 * time.Millisecond, Timeout: time.Second,
+		OnError: func(err error) { errs <- err }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	first := <-conns
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err = <-errs:
+		t.Fatalf("answered pings broke connection - %v", err)
+	default:
+	}
+
+	first.Close()
+	select {
+	case <-conns:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reconnect")
+	}
+	if len(errs) == 0 {
+		t.Error("broken connection is not reported")
+	}
+	// publishes fail until the new connection replaces the broken one
+	for i := 0; ; i++ {
+		if err = conn.Publish("dnevnik76/test", []byte("1"), false); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if topic := <-topics; topic != "dnevnik76/test" {
+		t.Errorf("published %q", topic)
+	}
+}
+
+func TestConn_StalledBroker(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		// answer connect and stop reading
+		r := bufio.NewReader(c)
+		r.ReadByte()
+		n, _ := r.ReadByte()
+		r.Discard(int(n))
+		c.Write([]byte{packetConnack, 2, 0, 0})
+		time.Sleep(5 * time.Second)
+	}()
+	conn, err := Dial(l.Addr().String(), Options{ClientID: "dnevnik76", Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		payload := make([]byte, 1<<20)
+		for i := 0; i < 64; i++ {
+			if err := conn.Publish("dnevnik76/test", payload, false); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err = <-done:
+		if err == nil {
+			t.Fatal("publishes to stalled broker succeeded")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("publish blocked by stalled broker")
+	}
+	closed := make(chan struct{})
+	go func() {
+		conn.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("close blocked by stalled broker")
+	}
+}
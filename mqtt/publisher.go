@@ -0,0 +1,231 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// Source of diary records, usually *dnevnik76.Client
+type Source interface {
+	GetMessagesCount() (unread int, total int, err error)
+	GetCurrentQuarter() string
+	GetMarksFor(p string) ([]dnevnik76.Mark, error)
+}
+
+// Publisher puts diary state on retained topics:
+//
+//	<prefix>/messages/unread
+//	<prefix>/messages/total
+//	<prefix>/average/<course>
+//	<prefix>/marks/latest
+type Publisher struct {
+	Conn   *Conn
+	Source Source
+	// Prefix of state topics, e.g. dnevnik76/08331111
+	Prefix string
+	// Node identifies the student in Home Assistant, e.g. 08331111
+	Node string
+	// DeviceName shown in Home Assistant
+	DeviceName string
+	// DiscoveryPrefix of Home Assistant, discovery is off when empty
+	DiscoveryPrefix string
+	// Latest is how many marks to put on marks/latest
+	Latest int
+}
+
+// LatestMark on marks/latest topic
+type LatestMark struct {
+	Date   string `json:"date"`
+	Course string `json:"course"`
+	Grades []int8 `json:"grades"`
+}
+
+// NewPublisher with default topics for login
+func NewPublisher(conn *Conn, src Source, login string) *Publisher {
+	return &Publisher{
+		Conn:            conn,
+		Source:          src,
+		Prefix:          "dnevnik76/" + login,
+		Node:            slug(login),
+		DeviceName:      "Дневник " + login,
+		DiscoveryPrefix: "homeassistant",
+		Latest:          10,
+	}
+}
+
+// Publish current state and Home Assistant discovery config
+func (p *Publisher) Publish() error {
+	unread, total, err := p.Source.GetMessagesCount()
+	if err != nil {
+		return err
+	}
+	marks, err := p.Source.GetMarksFor(p.Source.GetCurrentQuarter())
+	if err != nil {
+		return err
+	}
+
+	sensors := []sensor{
+		{id: "unread", name: "Непрочитанные сообщения", topic: "messages/unread", icon: "mdi:email"},
+		{id: "total", name: "Всего сообщений", topic: "messages/total", icon: "mdi:email-multiple"},
+	}
+	values := map[string]string{
+		"messages/unread": fmt.Sprint(unread),
+		"messages/total":  fmt.Sprint(total),
+	}
+	for _, a := range averages(marks) {
+		topic := "average/" + slug(a.course)
+		values[topic] = fmt.Sprintf("%.2f", a.avg)
+		sensors = append(sensors, sensor{id: "avg_" + slug(a.course), name: "Средний балл: " + a.course, topic: topic, icon: "mdi:school"})
+	}
+	latest, err := json.Marshal(latestMarks(marks, p.Latest))
+	if err != nil {
+		return err
+	}
+	values["marks/latest"] = string(latest)
+	sensors = append(sensors, sensor{id: "latest", name: "Последние оценки", topic: "marks/latest", icon: "mdi:format-list-numbered", json: true})
+
+	if p.DiscoveryPrefix != "" {
+		for _, s := range sensors {
+			if err = p.discover(s); err != nil {
+				return err
+			}
+		}
+	}
+	topics := make([]string, 0, len(values))
+	for t := range values {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	for _, t := range topics {
+		if err = p.Conn.Publish(p.Prefix+"/"+t, []byte(values[t]), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type sensor struct {
+	id, name, topic, icon string
+	json                  bool
+}
+
+// discover to publish Home Assistant MQTT discovery config of sensor
+func (p *Publisher) discover(s sensor) error {
+	uid := fmt.Sprintf("dnevnik76_%s_%s", p.Node, s.id)
+	config := map[string]interface{}{
+		"name":        s.name,
+		"unique_id":   uid,
+		"object_id":   uid,
+		"state_topic": p.Prefix + "/" + s.topic,
+		"icon":        s.icon,
+		"device": map[string]interface{}{
+			"identifiers":  []string{"dnevnik76_" + p.Node},
+			"name":         p.DeviceName,
+			"manufacturer": "dnevnik76.ru",
+		},
+	}
+	if s.json {
+		// the state keeps the number of marks, the list goes to attributes
+		config["value_template"] = "{{ value_json | count }}"
+		config["json_attributes_topic"] = p.Prefix + "/" + s.topic
+		config["json_attributes_template"] = `{"marks": {{ value }} }`
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return p.Conn.Publish(fmt.Sprintf("%s/sensor/%s/config", p.DiscoveryPrefix, uid), data, true)
+}
+
+type courseAverage struct {
+	course string
+	avg    float64
+}
+
+// averages of numeric grades per course
+func averages(marks []dnevnik76.Mark) (result []courseAverage) {
+	sums := map[string][2]int{}
+	for _, m := range marks {
+		course := strings.TrimSpace(m.CourseName)
+		for _, g := range m.Grade {
+			if g > 0 {
+				s := sums[course]
+				sums[course] = [2]int{s[0] + int(g), s[1] + 1}
+			}
+		}
+	}
+	for c, s := range sums {
+		result = append(result, courseAverage{course: c, avg: float64(s[0]) / float64(s[1])})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].course < result[j].course })
+	return
+}
+
+func latestMarks(marks []dnevnik76.Mark, n int) (result []LatestMark) {
+	graded := make([]dnevnik76.Mark, 0, len(marks))
+	for _, m := range marks {
+		if len(m.Grade) > 0 {
+			graded = append(graded, m)
+		}
+	}
+	sort.Stable(sort.Reverse(dnevnik76.MarksByDate(graded)))
+	for i, m := range graded {
+		if i == n {
+			break
+		}
+		result = append(result, LatestMark{Date: m.Date.Format("2006-01-02"), Course: strings.TrimSpace(m.CourseName), Grades: m.Grade})
+	}
+	return
+}
+
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// slug to get topic and object id safe name
+func slug(s string) string {
+	var sb strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			sb.WriteRune(r)
+			sep = false
+		case translit[r] != "":
+			sb.WriteString(translit[r])
+			sep = false
+		case r == 'ъ' || r == 'ь':
+		default:
+			if !sep && sb.Len() > 0 {
+				sb.WriteByte('_')
+				sep = true
+			}
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "_")
+}
+
+// Run to publish every interval until ctx is done, errors go to onError
+func (p *Publisher) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := p.Publish(); err != nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+type message struct {
+	topic   string
+	payload string
+	retain  bool
+}
+
+// fakeBroker accepts one client and collects its publishes until disconnect
+func fakeBroker(t *testing.T) (addr string, done <-chan []message, connect <-chan []byte) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	msgs := make(chan []message, 1)
+	conns := make(chan []byte, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		var got []message
+		for {
+			header, err := r.ReadByte()
+			if err != nil {
+				break
+			}
+			n, mul := 0, 1
+			for {
+				b, _ := r.ReadByte()
+				n += int(b&0x7f) * mul
+				mul *= 128
+				if b&0x80 == 0 {
+					break
+				}
+			}
+			body := make([]byte, n)
+			io.ReadFull(r, body)
+			switch header & 0xf0 {
+			case packetConnect:
+				conns <- body
+				c.Write([]byte{packetConnack, 2, 0, 0})
+			case packetPublish:
+				tl := binary.BigEndian.Uint16(body)
+				got = append(got, message{topic: string(body[2 : 2+tl]), payload: string(body[2+tl:]), retain: header&flagRetain != 0})
+			}
+			if header == packetDisconnect {
+				break
+			}
+		}
+		msgs <- got
+	}()
+	return l.Addr().String(), msgs, conns
+}
+
+type fakeSource struct{}
+
+func (fakeSource) GetMessagesCount() (int, int, error) { return 2, 15, nil }
+func (fakeSource) GetCurrentQuarter() string           { return "q1" }
+func (fakeSource) GetMarksFor(p string) ([]dnevnik76.Mark, error) {
+	day := func(d int) time.Time { return time.Date(2022, time.September, d, 0, 0, 0, 0, time.Local) }
+	return []dnevnik76.Mark{
+		{CourseName: "Алгебра ", Date: day(5), Grade: []int8{5, 4}},
+		{CourseName: "Алгебра ", Date: day(7), Grade: []int8{0}},
+		{CourseName: "Русский язык", Date: day(6), Grade: []int8{3}},
+		{CourseName: "Русский язык", Date: day(8)},
+	}, nil
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	addr, done, connect := fakeBroker(t)
+	conn, err := Dial(addr, Options{ClientID: "dnevnik76", Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := <-connect; string(c[2:6]) != "MQTT" || c[7] != 0xc2 {
+		t.Errorf("connect packet - %v", c)
+	}
+
+	p := NewPublisher(conn, fakeSource{}, "08331111")
+	p.Latest = 2
+	if err = p.Publish(); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	topics := map[string]message{}
+	for _, m := range <-done {
+		if !m.retain {
+			t.Errorf("%s is not retained", m.topic)
+		}
+		topics[m.topic] = m
+	}
+	want := map[string]string{
+		"dnevnik76/08331111/messages/unread":       "2",
+		"dnevnik76/08331111/messages/total":        "15",
+		"dnevnik76/08331111/average/algebra":       "4.50",
+		"dnevnik76/08331111/average/russkiy_yazyk": "3.00",
+		"dnevnik76/08331111/marks/latest":          `[{"date":"2022-09-07","course":"Алгебра","grades":[0]},{"date":"2022-09-06","course":"Русский язык","grades":[3]}]`,
+	}
+	for topic, payload := range want {
+		if topics[topic].payload != payload {
+			t.Errorf("%s - %q, want %q", topic, topics[topic].payload, payload)
+		}
+	}
+
+	var config map[string]interface{}
+	m, ok := topics["homeassistant/sensor/dnevnik76_08331111_avg_algebra/config"]
+	if !ok {
+		t.Fatalf("no discovery config, topics - %v", topics)
+	}
+	if err = json.Unmarshal([]byte(m.payload), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["state_topic"] != "dnevnik76/08331111/average/algebra" || config["unique_id"] != "dnevnik76_08331111_avg_algebra" {
+		t.Errorf("discovery config - %v", config)
+	}
+}
@@ -0,0 +1,34 @@
+// Package notify delivers watcher events to external receivers
+package notify
+
+import (
+	"context"
+
+	"github.com/bvp/dnevnik76-api/watch"
+)
+
+// Notifier delivers an event
+type Notifier interface {
+	Notify(ctx context.Context, e watch.Event) error
+}
+
+// Func adapts a function to Notifier
+type Func func(ctx context.Context, e watch.Event) error
+
+// Notify calls f
+func (f Func) Notify(ctx context.Context, e watch.Event) error {
+	return f(ctx, e)
+}
+
+// Multi delivers event to every notifier and returns the first error
+type Multi []Notifier
+
+// Notify every notifier
+func (m Multi) Notify(ctx context.Context, e watch.Event) (err error) {
+	for _, n := range m {
+		if nerr := n.Notify(ctx, e); nerr != nil && err == nil {
+			err = nerr
+		}
+	}
+	return
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bvp/dnevnik76-api/watch"
+)
+
+const (
+	// HeaderSignature carries HMAC-SHA256 of the body as "sha256=<hex>"
+	HeaderSignature = "X-Dnevnik76-Signature"
+	// HeaderEvent carries the event type
+	HeaderEvent = "X-Dnevnik76-Event"
+)
+
+// Webhook posts events as JSON to URL
+type Webhook struct {
+	URL string
+	// Secret to sign payloads with, no signature when empty
+	Secret []byte
+	Client *http.Client
+	// MaxAttempts of delivery before the payload goes to dead letters
+	MaxAttempts int
+	// Backoff before the second attempt, doubled for every next one
+	Backoff time.Duration
+	// DeadLetter file collecting undelivered payloads, one JSON per line
+	DeadLetter string
+
+	mu sync.Mutex
+}
+
+// DeadLetter is an undelivered payload
+type DeadLetter struct {
+	Time    time.Time       `json:"time"`
+	URL     string          `json:"url"`
+	Error   string          `json:"error"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewWebhook with default retry policy
+func NewWebhook(url string, secret []byte) *Webhook {
+	return &Webhook{
+		URL:         url,
+		Secret:      secret,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+	}
+}
+
+// Sign body with secret
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify signature of body
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Notify to post event, retrying on network and server errors
+func (wh *Webhook) Notify(ctx context.Context, e watch.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	attempts := wh.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := wh.Backoff
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return wh.deadLetter(body, ctx.Err())
+			case <-t.C:
+			}
+			backoff *= 2
+		}
+		var retry bool
+		retry, err = wh.post(ctx, e.Type.String(), body)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			break
+		}
+	}
+	return wh.deadLetter(body, err)
+}
+
+// post body once and report whether a failure is worth retrying
+func (wh *Webhook) post(ctx context.Context, event string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event)
+	if len(wh.Secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(wh.Secret, body))
+	}
+
+	client := wh.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook %s: %s", wh.URL, resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// deadLetter to keep undelivered body and return the delivery error
+func (wh *Webhook) deadLetter(body []byte, cause error) error {
+	if wh.DeadLetter == "" {
+		return cause
+	}
+	line, err := json.Marshal(DeadLetter{Time: time.Now(), URL: wh.URL, Error: cause.Error(), Payload: body})
+	if err != nil {
+		return err
+	}
+
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	f, err := os.OpenFile(wh.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("%v, dead letter: %w", cause, err)
+	}
+	defer f.Close()
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("%v, dead letter: %w", cause, err)
+	}
+	return cause
+}
+
+// ReadDeadLetters to load undelivered payloads from file
+func ReadDeadLetters(path string) (letters []DeadLetter, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var l DeadLetter
+		if err = json.Unmarshal([]byte(line), &l); err != nil {
+			return
+		}
+		letters = append(letters, l)
+	}
+	return
+}
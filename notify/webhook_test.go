@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/watch"
+)
+
+var secret = []byte("s3cret")
+
+func markEvent() watch.Event {
+	return watch.Event{Type: watch.NewMark, Mark: &dnevnik76.Mark{CourseName: "Физика", Grade: []int8{5}}}
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("bad signature %q", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get(HeaderEvent) != "mark" {
+			t.Errorf("event header - %q", r.Header.Get(HeaderEvent))
+		}
+		var e watch.Event
+		if err := json.Unmarshal(body, &e); err != nil || e.Mark.CourseName != "Физика" {
+			t.Errorf("payload %s: %v", body, err)
+		}
+	}))
+	defer srv.Close()
+
+	wh := NewWebhook(srv.URL, secret)
+	wh.Backoff = time.Millisecond
+	if err := wh.Notify(context.Background(), markEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("attempts - %d, want 3", calls)
+	}
+}
+
+func TestWebhook_DeadLetter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	wh := NewWebhook(srv.URL, secret)
+	wh.Backoff = time.Millisecond
+	wh.DeadLetter = filepath.Join(t.TempDir(), "dead.ndjson")
+	if err := wh.Notify(context.Background(), markEvent()); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	if calls != 1 {
+		t.Errorf("client error retried %d times", calls)
+	}
+
+	letters, err := ReadDeadLetters(wh.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].URL != srv.URL {
+		t.Fatalf("dead letters - %+v", letters)
+	}
+	var e watch.Event
+	if err = json.Unmarshal(letters[0].Payload, &e); err != nil || e.Type != watch.NewMark {
+		t.Errorf("dead letter payload %s: %v", letters[0].Payload, err)
+	}
+}
@@ -0,0 +1,128 @@
+// Package parse issues
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bvp/russiantime"
+)
+
+// ParseIssue is a part of the page that could not be read as expected.
+// Parsers skip or leave zero what they could not read and report an issue instead of failing.
+type ParseIssue struct {
+	// Parser that found the issue, e.g. MarksNote
+	Parser string `json:"parser"`
+	// Selector of the element
+	Selector string `json:"selector"`
+	// Row index within selection, -1 for the page itself
+	Row int `json:"row"`
+	// Raw text or attribute value that could not be read
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+func (i ParseIssue) String() string {
+	s := fmt.Sprintf("%s: %s", i.Parser, i.Selector)
+	if i.Row >= 0 {
+		s += fmt.Sprintf(" [%d]", i.Row)
+	}
+	s += ": " + i.Reason
+	if i.Raw != "" {
+		s += fmt.Sprintf(" (%q)", i.Raw)
+	}
+	return s
+}
+
+// IssuesError is returned in strict mode when a page had issues
+type IssuesError struct {
+	Issues []ParseIssue
+}
+
+func (e *IssuesError) Error() string {
+	if len(e.Issues) == 1 {
+		return "parse: " + e.Issues[0].String()
+	}
+	return fmt.Sprintf("parse: %s and %d more issues", e.Issues[0], len(e.Issues)-1)
+}
+
+// Check to turn issues into an error for strict mode, nil when there are none
+func Check(issues []ParseIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return &IssuesError{Issues: issues}
+}
+
+// collector of issues found by one parser
+type collector struct {
+	parser string
+	list   []ParseIssue
+}
+
+func (is *collector) add(selector string, row int, raw, reason string) {
+	is.list = append(is.list, ParseIssue{
+		Parser:   is.parser,
+		Selector: selector,
+		Row:      row,
+		Raw:      strings.TrimSpace(raw),
+		Reason:   reason,
+	})
+}
+
+// recover from a panic of a parser turning it into an issue, use with defer
+func (is *collector) recover() {
+	if r := recover(); r != nil {
+		is.add("", -1, "", fmt.Sprintf("panic: %v", r))
+	}
+}
+
+// id to read numeric option or record id
+func (is *collector) id(selector string, row int, raw string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+	if err != nil {
+		is.add(selector, row, raw, "not a number")
+		return 0, false
+	}
+	return id, true
+}
+
+// grade to read mark text, 0 is kept in place of an attendance symbol or an unreadable one
+func (is *collector) grade(selector string, row int, raw string) int8 {
+	g, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 8)
+	if err != nil {
+		if !attendance[strings.ToLower(strings.TrimSpace(raw))] {
+			is.add(selector, row, raw, "not a grade")
+		}
+		return 0
+	}
+	return int8(g)
+}
+
+// date to read russian date, zero time is kept in place of an unreadable one
+func (is *collector) date(selector string, row int, raw string) time.Time {
+	t, ok := date(raw)
+	if !ok {
+		is.add(selector, row, raw, "not a date")
+	}
+	return t
+}
+
+var (
+	// attendance symbols put in place of grades, e.g. н for absent
+	attendance = map[string]bool{"н": true, "нб": true, "б": true, "у": true, "уп": true, "п": true, "оп": true, "осв": true}
+	ruMonths   = [...]string{"", "января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+)
+
+// date to parse russian date like "5 сентября 2022 г." with optional time by russiantime,
+// which gives year 0 for an unreadable string and the December before for an unknown month
+func date(s string) (time.Time, bool) {
+	t := russiantime.ParseDateString(s)
+	if t.Year() < 1 || !strings.Contains(s, " "+ruMonths[t.Month()]+" ") {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,298 @@
+// Package parse marks
+package parse
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/bvp/dnevnik76-api/model"
+)
+
+var reDigits = regexp.MustCompile("[0-9]+")
+
+// ParsePeriods to get marks periods offered by the #mark_range selector, without dates
+func ParsePeriods(r io.Reader, info model.CurrentInfo) ([]model.Lperiod, []ParseIssue, error) {
+	return defaultProfile.ParsePeriods(r, info)
+}
+
+// ParsePeriods to get marks periods offered by the #mark_range selector, without dates
+func (p *SelectorProfile) ParsePeriods(r io.Reader, info model.CurrentInfo) (periods []model.Lperiod, issues []ParseIssue, err error) {
+	is := &collector{parser: "Periods"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Periods.Options
+	doc.Find(sel).Each(func(i int, s *goquery.Selection) {
+		value, _ := s.Attr("value")
+		if value == "" {
+			is.add(sel, i, s.Text(), "no period value")
+			return
+		}
+		periods = append(periods, model.Lperiod{
+			SchoolID: info.SchoolID,
+			SYear:    info.EduYearStart,
+			EYear:    info.EduYearEnd,
+			Name:     strings.TrimSpace(s.Text()),
+			Period:   value,
+		})
+	})
+	return
+}
+
+// ParsePeriodRange to read period dates from the marks page heading
+func ParsePeriodRange(r io.Reader) (start, end time.Time, issues []ParseIssue, err error) {
+	return defaultProfile.ParsePeriodRange(r)
+}
+
+// ParsePeriodRange to read period dates from the marks page heading
+func (p *SelectorProfile) ParsePeriodRange(r io.Reader) (start, end time.Time, issues []ParseIssue, err error) {
+	is := &collector{parser: "PeriodRange"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	re, idx, err := p.Periods.Range.compile("start", "end")
+	if err != nil {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Periods.Heading
+	text := doc.Find(sel).First().Text()
+	result := re.FindStringSubmatch(text)
+	if result == nil {
+		is.add(sel, -1, text, "no period dates")
+		return
+	}
+	start = is.date(sel, -1, result[idx[0]])
+	end = is.date(sel, -1, result[idx[1]])
+	return
+}
+
+// ParseMarksNote to get marks of the student diary view, one mark per lesson
+func ParseMarksNote(r io.Reader, info model.CurrentInfo) ([]model.Mark, []ParseIssue, error) {
+	return defaultProfile.ParseMarksNote(r, info)
+}
+
+// ParseMarksNote to get marks of the student diary view, one mark per lesson
+func (p *SelectorProfile) ParseMarksNote(r io.Reader, info model.CurrentInfo) (marks []model.Mark, issues []ParseIssue, err error) {
+	is := &collector{parser: "MarksNote"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Note
+	selTitle := sel.Days + " " + sel.Title
+	selMarks := sel.Days + " " + sel.Rows + " > " + sel.Marks
+	row := 0
+	doc.Find(sel.Days).Each(func(j int, s2 *goquery.Selection) {
+		title := strings.TrimSpace(s2.Find(sel.Title).First().Text())
+		pd := strings.Split(strings.TrimRight(title, ")"), " (")
+		rows := s2.Find(sel.Rows)
+		if len(pd) < 2 {
+			is.add(selTitle, row, title, "no lesson date, day skipped")
+			row += rows.Size()
+			return
+		}
+		dayOfWeek := pd[0]
+		date := is.date(selTitle, row, pd[1])
+		rows.Each(func(k int, tr *goquery.Selection) {
+			mark := newMark(info)
+			mark.DayOfWeek = dayOfWeek
+			mark.Date = date
+
+			course := tr.Find(sel.Course).First().Text()
+			mark.CourseName = course
+			pt, _ := tr.Attr("title")
+			lessonTitle := strings.TrimSpace(strings.TrimLeft(pt, "Тема: "))
+			mark.Subject = lessonTitle
+			hw := tr.Find(sel.Homework).First().Text()
+			mark.HomeWork = strings.TrimSpace(hw)
+			tr.Find(sel.Marks).Each(func(l int, m *goquery.Selection) {
+				mark.Grade = append(mark.Grade, is.grade(selMarks, row, m.Text()))
+			})
+			marks = append(marks, mark)
+			row++
+		})
+	})
+	markPositions(marks)
+	return
+}
+
+// ParseMarksList to get marks of the list view, one mark per grade
+func ParseMarksList(r io.Reader, info model.CurrentInfo) ([]model.Mark, []ParseIssue, error) {
+	return defaultProfile.ParseMarksList(r, info)
+}
+
+// ParseMarksList to get marks of the list view, one mark per grade
+func (p *SelectorProfile) ParseMarksList(r io.Reader, info model.CurrentInfo) (marks []model.Mark, issues []ParseIssue, err error) {
+	is := &collector{parser: "MarksList"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	re, idx, err := p.List.MarkInfo.compile("date")
+	if err != nil {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	// TODO: fill DayOfWeek, Subject, HomeWork
+	sel := p.List
+	selLink := sel.Rows + " " + sel.Marks + " > " + sel.Link
+	doc.Find(sel.Rows).Each(func(i int, s *goquery.Selection) {
+		courseName := s.Find(sel.Label).Text()
+		s.Find(sel.Marks).Each(func(j int, sj *goquery.Selection) {
+			mark := newMark(info)
+			mark.CourseName = courseName
+			el := sj.Find(sel.Link).First()
+			onClick, _ := el.Attr("onclick")
+			var d string
+			if m := re.FindStringSubmatch(onClick); m != nil {
+				d = m[idx[0]]
+			}
+			mark.Date = is.date(selLink+"[onclick]", i, d)
+			mark.Grade = append(mark.Grade, is.grade(selLink, i, el.Text()))
+			marks = append(marks, mark)
+		})
+	})
+	markPositions(marks)
+	return
+}
+
+// ParseMarksAverages to get course averages the list view shows for period
+func ParseMarksAverages(r io.Reader, period string) ([]model.CourseAverage, []ParseIssue, error) {
+	return defaultProfile.ParseMarksAverages(r, period)
+}
+
+// ParseMarksAverages to get course averages the list view shows for period
+func (p *SelectorProfile) ParseMarksAverages(r io.Reader, period string) (avgs []model.CourseAverage, issues []ParseIssue, err error) {
+	is := &collector{parser: "MarksAverages"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.List
+	doc.Find(sel.Rows).Each(func(i int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Find(sel.Average).First().Text())
+		if text == "" {
+			return
+		}
+		avg, perr := strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64)
+		if perr != nil {
+			is.add(sel.Rows+" "+sel.Average, i, text, "not an average")
+			return
+		}
+		avgs = append(avgs, model.CourseAverage{
+			Period:     period,
+			CourseName: strings.TrimSpace(s.Find(sel.Label).Text()),
+			Average:    avg,
+		})
+	})
+	return
+}
+
+// ParseMarksFinal to get quarter and annual marks, course names are taken from courses
+func ParseMarksFinal(r io.Reader, info model.CurrentInfo, courses []model.Course) ([]model.Mark, []ParseIssue, error) {
+	return defaultProfile.ParseMarksFinal(r, info, courses)
+}
+
+// ParseMarksFinal to get quarter and annual marks, course names are taken from courses
+func (p *SelectorProfile) ParseMarksFinal(r io.Reader, info model.CurrentInfo, courses []model.Course) (marks []model.Mark, issues []ParseIssue, err error) {
+	is := &collector{parser: "MarksFinal"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	re, idx, err := p.Final.ItogInfo.compile("period")
+	if err != nil {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Final
+	doc.Find(sel.Rows).Each(func(i int, s *goquery.Selection) {
+		value, _ := s.Attr("name")
+		courseID, _ := is.id(sel.Rows+"[name]", i, value)
+		courseName := ""
+		for _, c := range courses {
+			if c.ID == courseID {
+				courseName = c.Name
+				break
+			}
+		}
+		if courseName == "" && courseID != 0 {
+			is.add(sel.Rows+"[name]", i, value, "unknown course")
+		}
+
+		s.Find(sel.Marks).Each(func(j int, sj *goquery.Selection) {
+			mark := newMark(info)
+			mark.CourseID = courseID
+			mark.CourseName = courseName
+			var onClick string
+			data := func() (period string, fmark string) {
+				el := sj.Find(sel.Link).First()
+				onClick, _ = el.Attr("onclick")
+				fmark = el.Text()
+				if m := re.FindStringSubmatch(onClick); m != nil {
+					period = m[idx[0]]
+				}
+				return
+			}
+			// not graded yet slots are kept as 0
+			grade := func(selector, fmark string) int8 {
+				if strings.TrimSpace(fmark) == "" {
+					return 0
+				}
+				return is.grade(selector, i, fmark)
+			}
+			if sj.Is(sel.Quarter) {
+				period, fmark := data()
+				digs := reDigits.FindAllString(period, -1)
+				if len(digs) > 0 {
+					mp, _ := strconv.ParseInt(digs[0], 10, 32)
+					mark.Quarter = int(mp)
+					mark.Term = model.Lperiod{Name: period}.Kind()
+				} else if strings.TrimSpace(fmark) != "" {
+					is.add(sel.Rows+" "+sel.Quarter+" > "+sel.Link+"[onclick]", i, onClick, "no quarter")
+				}
+				mark.Grade = append(mark.Grade, grade(sel.Rows+" "+sel.Quarter+" > "+sel.Link, fmark))
+				marks = append(marks, mark)
+			} else if sj.Is(sel.Annual) {
+				_, fmark := data()
+				mark.Annual = true
+				mark.Grade = append(mark.Grade, grade(sel.Rows+" "+sel.Annual+" > "+sel.Link, fmark))
+				marks = append(marks, mark)
+			}
+		})
+	})
+	return
+}
+
+func newMark(info model.CurrentInfo) model.Mark {
+	return model.Mark{SchoolID: info.SchoolID, SYear: info.EduYearStart, EYear: info.EduYearEnd}
+}
+
+func markPositions(marks []model.Mark) {
+	for i, pos := range model.Positions(marks, model.Mark.Key, func(m *model.Mark) *int { return &m.Position }) {
+		marks[i].Position = pos
+	}
+}
+
+func homeworkPositions(hws []model.Homework) {
+	for i, pos := range model.Positions(hws, model.Homework.Key, func(h *model.Homework) *int { return &h.Position }) {
+		hws[i].Position = pos
+	}
+}
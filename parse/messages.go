@@ -0,0 +1,101 @@
+// Package parse messages
+package parse
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/bvp/dnevnik76-api/model"
+)
+
+// ParseMessagesCount to read unread and total messages from the counter JSON
+func ParseMessagesCount(r io.Reader) (unread int, total int, issues []ParseIssue, err error) {
+	is := &collector{parser: "MessagesCount"}
+	defer func() { issues = is.list }()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	respMap := make(map[string]int)
+	if jerr := json.Unmarshal(body, &respMap); jerr != nil {
+		is.add("", -1, string(body), jerr.Error())
+	}
+
+	unread, total = respMap["unread_messages"], respMap["all_messages"]
+	return
+}
+
+// ParseMessages to get the inbox list, bodies are not included
+func ParseMessages(r io.Reader) ([]model.Message, []ParseIssue, error) {
+	return defaultProfile.ParseMessages(r)
+}
+
+// ParseMessages to get the inbox list, bodies are not included
+func (p *SelectorProfile) ParseMessages(r io.Reader) (messages []model.Message, issues []ParseIssue, err error) {
+	is := &collector{parser: "Messages"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+
+	sel := p.Messages
+	pagesFlag := doc.Find(sel.PagerRemark).Text()
+	if pagesFlag != "" {
+		pages := doc.Find(sel.Pages)
+		if pages.Size() < 2 {
+			is.add(sel.Pages, -1, pagesFlag, "no page count")
+		} else {
+			is.id(sel.Pages, pages.Size()-2, pages.Eq(pages.Size()-2).Text())
+		}
+	}
+
+	doc.Find(sel.Rows).Each(func(i int, s *goquery.Selection) {
+		message := model.Message{}
+		msgID, _ := s.Find(sel.ID).Attr("value")
+		message.ID, _ = is.id(sel.Rows+" > "+sel.ID, i, msgID)
+		title := s.Find(sel.Subject)
+		message.Subject = strings.TrimSpace(title.Text())
+		if title.HasClass("unread") {
+			message.IsUnread = true
+		}
+		from := s.Find(sel.From).Text()
+		message.From = from
+		message.Date = is.date(sel.Rows+" > "+sel.Date, i, s.Find(sel.Date).Text())
+		messages = append(messages, message)
+	})
+	return
+}
+
+// ParseMessage to get message page with body
+func ParseMessage(r io.Reader, msgID int64) (model.Message, []ParseIssue, error) {
+	return defaultProfile.ParseMessage(r, msgID)
+}
+
+// ParseMessage to get message page with body
+func (p *SelectorProfile) ParseMessage(r io.Reader, msgID int64) (m model.Message, issues []ParseIssue, err error) {
+	is := &collector{parser: "Message"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	m.ID = msgID
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+
+	sel := p.Messages
+	msgDate := strings.TrimPrefix(doc.Find(sel.ViewDate).First().Text(), "Дата: ")
+	m.Date = is.date(sel.ViewDate, -1, msgDate)
+	msgFrom := doc.Find(sel.ViewFrom).First().Text()
+	m.From = msgFrom
+	msgText := doc.Find(sel.ViewText)
+	if msgText.Size() == 0 {
+		is.add(sel.ViewText, -1, "", "no message text")
+	}
+	m.Body = msgText.First().Text()
+	return
+}
@@ -0,0 +1,294 @@
+// Package parse reads diary records from my.dnevnik76.ru pages without fetching them,
+// so saved pages can be parsed and tested offline
+package parse
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/bvp/dnevnik76-api/model"
+)
+
+var reInsideSpace = regexp.MustCompile(`[\s\p{Zs}]{2,}`)
+
+// defaultProfile is used by package level parsers
+var defaultProfile = DefaultProfile()
+
+// ParseLoginToken to get CSRF token of the login form
+func ParseLoginToken(r io.Reader) (string, []ParseIssue, error) {
+	return defaultProfile.ParseLoginToken(r)
+}
+
+// ParseLoginToken to get CSRF token of the login form
+func (p *SelectorProfile) ParseLoginToken(r io.Reader) (token string, issues []ParseIssue, err error) {
+	is := &collector{parser: "LoginToken"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	token, _ = doc.Find(p.Login.Token).First().Attr("value")
+	if token == "" {
+		is.add(p.Login.Token, -1, "", "no token")
+	}
+	return
+}
+
+// ParseCurrentInfo to get class and academic year from the homework page
+func ParseCurrentInfo(r io.Reader) (model.CurrentInfo, []ParseIssue, error) {
+	return defaultProfile.ParseCurrentInfo(r)
+}
+
+// ParseCurrentInfo to get class and academic year from the homework page
+func (p *SelectorProfile) ParseCurrentInfo(r io.Reader) (info model.CurrentInfo, issues []ParseIssue, err error) {
+	is := &collector{parser: "CurrentInfo"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	reYear, years, err := p.Info.YearRange.compile("start", "end")
+	if err != nil {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+
+	info.Class = className(doc.Find(p.Info.Role).Text())
+	if info.ClassID, err = p.classID(doc, is); err != nil {
+		return
+	}
+
+	text := doc.Find(p.Info.EduYear).Text()
+	m := reYear.FindStringSubmatch(text)
+	if m == nil {
+		is.add(p.Info.EduYear, -1, text, "no academic year")
+		return
+	}
+	info.EduYearStart, _ = strconv.Atoi(m[years[0]])
+	info.EduYearEnd, _ = strconv.Atoi(m[years[1]])
+	return
+}
+
+// ParseEduYears to get academic years offered by the #eduyear selector, newest first
+func ParseEduYears(r io.Reader) ([]model.EduYear, []ParseIssue, error) {
+	return defaultProfile.ParseEduYears(r)
+}
+
+// ParseEduYears to get academic years offered by the #eduyear selector, newest first
+func (p *SelectorProfile) ParseEduYears(r io.Reader) (years []model.EduYear, issues []ParseIssue, err error) {
+	is := &collector{parser: "EduYears"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	reYear, idx, err := p.Info.YearRange.compile("start")
+	if err != nil {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	seen := map[model.EduYear]bool{}
+	for _, m := range reYear.FindAllStringSubmatch(doc.Find(p.Info.EduYears).Text(), -1) {
+		y, _ := strconv.Atoi(m[idx[0]])
+		if !seen[model.EduYear(y)] {
+			seen[model.EduYear(y)] = true
+			years = append(years, model.EduYear(y))
+		}
+	}
+	if len(years) == 0 {
+		is.add(p.Info.EduYears, -1, "", "no academic years")
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i] > years[j] })
+	return
+}
+
+// ParseRegions to get regions from the login form selector
+func ParseRegions(r io.Reader) ([]model.Region, []ParseIssue, error) {
+	return defaultProfile.ParseRegions(r)
+}
+
+// ParseRegions to get regions from the login form selector
+func (p *SelectorProfile) ParseRegions(r io.Reader) (regions []model.Region, issues []ParseIssue, err error) {
+	is := &collector{parser: "Regions"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Options.Options
+	doc.Find(sel).Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Text())
+		value, _ := s.Attr("value")
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		if regionID, ok := is.id(sel, i, value); ok && regionID != 0 {
+			regions = append(regions, model.Region{ID: regionID, Name: title})
+		}
+	})
+	return
+}
+
+// ParseSchools to get schools of region grouped by school type
+func ParseSchools(r io.Reader, regionID int64) ([]model.School, []ParseIssue, error) {
+	return defaultProfile.ParseSchools(r, regionID)
+}
+
+// ParseSchools to get schools of region grouped by school type
+func (p *SelectorProfile) ParseSchools(r io.Reader, regionID int64) (schools []model.School, issues []ParseIssue, err error) {
+	is := &collector{parser: "Schools"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Options.Groups + " " + p.Options.GroupOptions
+	row := 0
+	doc.Find(p.Options.Groups).Each(func(i int, s *goquery.Selection) {
+		label, _ := s.Attr("label")
+		s.Find(p.Options.GroupOptions).Each(func(i int, s2 *goquery.Selection) {
+			title := strings.TrimSpace(s2.Text())
+			value, _ := s2.Attr("value")
+			if schoolID, ok := is.id(sel, row, value); ok {
+				schools = append(schools, model.School{ID: schoolID, RegionID: regionID, Name: title, Type: label})
+			}
+			row++
+		})
+	})
+	return
+}
+
+// ParseCourses to get subjects from the class subjects selector
+func ParseCourses(r io.Reader) ([]model.Course, []ParseIssue, error) {
+	return defaultProfile.ParseCourses(r)
+}
+
+// ParseCourses to get subjects from the class subjects selector
+func (p *SelectorProfile) ParseCourses(r io.Reader) (courses []model.Course, issues []ParseIssue, err error) {
+	is := &collector{parser: "Courses"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Options.Options
+	doc.Find(sel).Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Text())
+		value, _ := s.Attr("value")
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		if courseID, ok := is.id(sel, i, value); ok && courseID != 0 {
+			courses = append(courses, model.Course{ID: courseID, Name: title})
+		}
+	})
+	return
+}
+
+// ParseHomework to get homework list of the class, ClassID is read from the page
+func ParseHomework(r io.Reader, info model.CurrentInfo) ([]model.Homework, []ParseIssue, error) {
+	return defaultProfile.ParseHomework(r, info)
+}
+
+// ParseHomework to get homework list of the class, ClassID is read from the page
+func (p *SelectorProfile) ParseHomework(r io.Reader, info model.CurrentInfo) (hws []model.Homework, issues []ParseIssue, err error) {
+	is := &collector{parser: "Homework"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	classID, err := p.classID(doc, is)
+	if err != nil {
+		return
+	}
+
+	sel := p.Homework
+	doc.Find(sel.Rows).Each(func(i int, s *goquery.Selection) {
+		h := model.Homework{}
+		h.SchoolID = info.SchoolID
+		h.ClassID = classID
+		h.Date = is.date(sel.Rows+" > "+sel.Date, i, s.Find(sel.Date).Text())
+		wday := s.Find(sel.Weekday).Text()
+		h.DayOfWeek = wday
+		course := s.Find(sel.Course).Text()
+		h.CourseName = course
+		hw := s.Find(sel.Homework).Text()
+		h.Homework = strings.TrimSpace(hw)
+		subject := s.Find(sel.Subject).Text()
+		h.Subject = strings.TrimSpace(subject)
+
+		hws = append(hws, h)
+	})
+	homeworkPositions(hws)
+	return
+}
+
+// ParseTeachers to get class teachers
+func ParseTeachers(r io.Reader, schoolID int64) ([]model.Teacher, []ParseIssue, error) {
+	return defaultProfile.ParseTeachers(r, schoolID)
+}
+
+// ParseTeachers to get class teachers
+func (p *SelectorProfile) ParseTeachers(r io.Reader, schoolID int64) (teachers []model.Teacher, issues []ParseIssue, err error) {
+	is := &collector{parser: "Teachers"}
+	defer func() { issues = is.list }()
+	defer is.recover()
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return
+	}
+	sel := p.Teachers
+	doc.Find(sel.Rows).Each(func(i int, t *goquery.Selection) {
+		teacher := model.Teacher{}
+		teacher.SchoolID = schoolID
+		el := t.Find(sel.Mailto)
+		href, _ := el.Attr("href")
+		teacher.UserID = strings.TrimSuffix(
+			strings.TrimPrefix(href, "/messages/new/?to="), fmt.Sprintf("@%d", schoolID))
+		if teacher.UserID == "" {
+			is.add(sel.Rows+" > "+sel.Mailto, i, href, "no teacher login")
+		}
+		fio := t.Find(sel.Name).Text()
+		teacher.FullName = fio
+		courseName := strings.TrimSpace(t.Find(sel.Course).Text())
+		if courseName == "" {
+			courseName = strings.TrimSpace(t.Find(sel.Courses).Text())
+		}
+		teacher.CourseName = courseName
+		teachers = append(teachers, teacher)
+	})
+	return
+}
+
+// classID from the subjects loader of the page body
+func (p *SelectorProfile) classID(doc *goquery.Document, is *collector) (id int64, err error) {
+	re, idx, err := p.Info.LoadSubjects.compile("class")
+	if err != nil {
+		return
+	}
+	onload, _ := doc.Find(p.Info.ClassLoader).Attr("onload")
+	m := re.FindStringSubmatch(onload)
+	if m == nil {
+		is.add(p.Info.ClassLoader+"[onload]", -1, onload, "no class id")
+		return
+	}
+	id, _ = strconv.ParseInt(m[idx[0]], 10, 64)
+	return
+}
+
+func className(s string) string {
+	final := reInsideSpace.ReplaceAllString(strings.TrimSpace(s), " ")
+	return strings.TrimLeft(strings.TrimRight(final, ")"), "Учащийся (")
+}
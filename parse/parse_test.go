@@ -0,0 +1,253 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bvp/dnevnik76-api/model"
+)
+
+var info = model.CurrentInfo{SchoolID: 760215, EduYearStart: 2022, EduYearEnd: 2023}
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2022, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func must(t *testing.T, issues []ParseIssue, err error) {
+	t.Helper()
+	if err == nil {
+		err = Check(issues)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseCurrentInfo(t *testing.T) {
+	page := `<body onload="loadSubjects('/ajax/subj/121', true)">
+		<div id="auth_info"><span id="role">Учащийся   (7А)</span></div>
+		<div id="eduyear"><span id="curedy">2022-2023 учебный год</span>
+		<a>2021-2022</a><a>2020-2021</a></div></body>`
+	got, issues, err := ParseCurrentInfo(strings.NewReader(page))
+	must(t, issues, err)
+	want := model.CurrentInfo{Class: "7А", ClassID: 121, EduYearStart: 2022, EduYearEnd: 2023}
+	if got != want {
+		t.Errorf("info %+v, want %+v", got, want)
+	}
+	years, _, _ := ParseEduYears(strings.NewReader(page))
+	if !reflect.DeepEqual(years, []model.EduYear{2022, 2021, 2020}) {
+		t.Errorf("years - %v", years)
+	}
+}
+
+func TestParseMarksNote(t *testing.T) {
+	page := `<div id="marks"><div class="week"><div class="dayofweek">
+		<div class="weekday"><h3>Понедельник (5 сентября 2022 г.)</h3></div>
+		<table><tbody>
+		<tr title="Тема: Дроби"><td>Математика</td><td> № 1 </td><td class="col-mark"><span class="mark">5</span><span class="mark">4</span></td></tr>
+		<tr><td>Математика</td><td></td><td class="col-mark"></td></tr>
+		<tr><td>Физика</td><td></td><td class="col-mark"><span class="mark">Н</span></td></tr>
+		</tbody></table></div></div></div>`
+	marks, issues, err := ParseMarksNote(strings.NewReader(page), info)
+	must(t, issues, err)
+	if len(marks) != 3 {
+		t.Fatalf("marks - %v", marks)
+	}
+	m := marks[0]
+	if m.CourseName != "Математика" || m.Subject != "Дроби" || m.HomeWork != "№ 1" || m.DayOfWeek != "Понедельник" ||
+		!m.Date.Equal(day(9, 5)) || !reflect.DeepEqual(m.Grade, []int8{5, 4}) || m.SchoolID != 760215 || m.SYear != 2022 {
+		t.Errorf("mark - %+v", m)
+	}
+	if marks[1].Position != 1 || marks[1].Grade != nil {
+		t.Errorf("second lesson - %+v", marks[1])
+	}
+	if !reflect.DeepEqual(marks[2].Grade, []int8{0}) {
+		t.Errorf("absence - %+v", marks[2])
+	}
+}
+
+func TestDate(t *testing.T) {
+	for s, want := range map[string]time.Time{
+		"5 сентября 2022 г.":       day(9, 5),
+		"17 декабря 2022 г. 18:09": time.Date(2022, time.December, 17, 18, 9, 0, 0, time.Local),
+		"6 сентябрь 2022 г.":       {},
+		"вчера":                    {},
+	} {
+		if got, ok := date(s); !got.Equal(want) || ok == want.IsZero() {
+			t.Errorf("%q - %s, %t", s, got, ok)
+		}
+	}
+}
+
+func TestParseMarksList(t *testing.T) {
+	page := `<div id="marks"><div id="mark-row"><div class="mark-label">Физика</div>
+		<span class="mark"><a onclick="showMarkInfo('6 сентября 2022 г. (Вторник)', 1)">3</a></span>
+		<span class="mark"><a onclick="showMarkInfo('6 сентября 2022 г. (Вторник)', 2)">4</a></span>
+		<span class="mark avg">3,50</span></div></div>`
+	marks, issues, err := ParseMarksList(strings.NewReader(page), info)
+	must(t, issues, err)
+	if len(marks) != 2 || !marks[1].Date.Equal(day(9, 6)) || marks[1].Grade[0] != 4 || marks[1].Position != 1 {
+		t.Errorf("marks - %v", marks)
+	}
+	avgs, _, _ := ParseMarksAverages(strings.NewReader(page), "q1")
+	if !reflect.DeepEqual(avgs, []model.CourseAverage{{Period: "q1", CourseName: "Физика", Average: 3.5}}) {
+		t.Errorf("averages - %v", avgs)
+	}
+}
+
+func TestParseMarksFinal(t *testing.T) {
+	page := `<div id="marks"><div id="wrap-col"><div id="wrap-marks"><div><div id="mark-row" name="11">
+		<span class="mark itg-q"><a onclick="showMarkItogInfo('2 четверть')">4</a></span>
+		<span class="mark itg-q"><a onclick="showMarkItogInfo('1 полугодие')">4</a></span>
+		<span class="mark itg-y"><a onclick="showMarkItogInfo('Год')">5</a></span></div></div></div></div></div>`
+	marks, issues, err := ParseMarksFinal(strings.NewReader(page), info, []model.Course{{ID: 11, Name: "Математика"}})
+	must(t, issues, err)
+	if len(marks) != 3 || marks[0].Quarter != 2 || marks[0].CourseName != "Математика" || !marks[2].Annual || marks[2].Grade[0] != 5 {
+		t.Errorf("final - %v", marks)
+	}
+	// the first half-year is not the first quarter
+	if marks[0].Term != model.PeriodQuarter || marks[1].Quarter != 1 || marks[1].Term != model.PeriodHalfYear {
+		t.Errorf("terms - %v", marks)
+	}
+}
+
+func TestParsePeriods(t *testing.T) {
+	periods, issues, err := ParsePeriods(strings.NewReader(`<select id="mark_range">
+		<optgroup label="Четверти"><option value="q1"> 1 четверть </option></optgroup></select>`), info)
+	must(t, issues, err)
+	if len(periods) != 1 || periods[0].Name != "1 четверть" || periods[0].Period != "q1" || periods[0].SYear != 2022 {
+		t.Errorf("periods - %v", periods)
+	}
+	start, end, issues, err := ParsePeriodRange(strings.NewReader(`<div id="content"><h3>с 1 сентября 2022 г. по 28 октября 2022 г.</h3></div>`))
+	must(t, issues, err)
+	if !start.Equal(day(9, 1)) || !end.Equal(day(10, 28)) {
+		t.Errorf("range %s - %s (%v)", start, end, err)
+	}
+}
+
+func TestParseMessages(t *testing.T) {
+	list := `<div id="content"><form><table class="list"><tbody><tr class="odd">
+		<td><input type="checkbox" class="message_mark" name="marks" value="123456"/></td>
+		<td><a href="/messages/input/123456/" class="unread"> Изменение режима работы школы </a></td>
+		<td>Фамилия Имя Отчество</td>
+		<td>17 декабря 2018 г. 18:09</td></tr></tbody></table></form></div>`
+	msgs, issues, err := ParseMessages(strings.NewReader(list))
+	must(t, issues, err)
+	if len(msgs) != 1 || msgs[0].ID != 123456 || !msgs[0].IsUnread || msgs[0].Subject != "Изменение режима работы школы" {
+		t.Errorf("messages - %v", msgs)
+	}
+
+	msg, issues, err := ParseMessage(strings.NewReader(`<div id="msgview"><div class="msg-meta"><div class="msg-props">
+		<div>Дата: 17 декабря 2018 г. 18:09</div><div>От: <a></a><a>Фамилия Имя Отчество</a></div></div></div>
+		<div class="msg-text">Уважаемые родители!</div></div>`), 123456)
+	must(t, issues, err)
+	if msg.ID != 123456 || msg.From != "Фамилия Имя Отчество" || msg.Body != "Уважаемые родители!" || msg.Date.Year() != 2018 {
+		t.Errorf("message - %+v", msg)
+	}
+
+	unread, total, issues, err := ParseMessagesCount(strings.NewReader(`{"unread_messages": 2, "all_messages": 40}`))
+	must(t, issues, err)
+	if unread != 2 || total != 40 {
+		t.Errorf("count %d/%d (%v)", unread, total, err)
+	}
+}
+
+func TestParseHomework(t *testing.T) {
+	page := `<body onload="loadSubjects('/ajax/subj/121', true)"><div id="homework_list"><table class="list"><tbody>
+		<tr><td>5 сентября 2022 г.</td><td>Пн</td><td><a>Математика</a></td><td> № 1 </td><td>Дроби</td></tr>
+		<tr><td>5 сентября 2022 г.</td><td>Пн</td><td><a>Математика</a></td><td>№ 2</td><td>Дроби</td></tr>
+		</tbody></table></div></body>`
+	hws, issues, err := ParseHomework(strings.NewReader(page), info)
+	must(t, issues, err)
+	if len(hws) != 2 || hws[0].ClassID != 121 || hws[0].Homework != "№ 1" || hws[1].Position != 1 || !hws[0].Date.Equal(day(9, 5)) {
+		t.Errorf("homework - %+v", hws)
+	}
+}
+
+func TestParseTeachers(t *testing.T) {
+	teachers, issues, err := ParseTeachers(strings.NewReader(`<div id="content"><table class="list"><tbody><tr><td></td>
+		<td>Иванова Мария Петровна</td><td><b>Математика</b></td>
+		<td class="action_links"><a class="mailto" href="/messages/new/?to=ivanova@760215"></a></td></tr></tbody></table></div>`), 760215)
+	must(t, issues, err)
+	want := []model.Teacher{{UserID: "ivanova", SchoolID: 760215, FullName: "Иванова Мария Петровна", CourseName: "Математика"}}
+	if !reflect.DeepEqual(teachers, want) {
+		t.Errorf("teachers %+v, want %+v", teachers, want)
+	}
+}
+
+func TestParseRegionsSchools(t *testing.T) {
+	regions, _, _ := ParseRegions(strings.NewReader(`<select><option value="0">---</option><option value="2"> Ярославль </option></select>`))
+	schools, _, _ := ParseSchools(strings.NewReader(`<select><optgroup label="Школы"><option value="760215">Школа № 83</option></optgroup></select>`), 2)
+	courses, _, _ := ParseCourses(strings.NewReader(`<select><option value="0">Все</option><option value="11">Математика</option></select>`))
+	if !reflect.DeepEqual(regions, []model.Region{{ID: 2, Name: "Ярославль"}}) {
+		t.Errorf("regions - %v", regions)
+	}
+	if !reflect.DeepEqual(schools, []model.School{{ID: 760215, RegionID: 2, Name: "Школа № 83", Type: "Школы"}}) {
+		t.Errorf("schools - %v", schools)
+	}
+	if !reflect.DeepEqual(courses, []model.Course{{ID: 11, Name: "Математика"}}) {
+		t.Errorf("courses - %v", courses)
+	}
+}
+
+func TestParseIssues(t *testing.T) {
+	reasons := func(issues []ParseIssue) (rs []string) {
+		for _, is := range issues {
+			rs = append(rs, is.Reason)
+		}
+		return
+	}
+
+	info, issues, err := ParseCurrentInfo(strings.NewReader(`<div id="eduyear"><span id="curedy">учебный год</span></div>`))
+	if err != nil || info.ClassID != 0 || !reflect.DeepEqual(reasons(issues), []string{"no class id", "no academic year"}) {
+		t.Errorf("info %+v - %v (%v)", info, issues, err)
+	}
+
+	marks, issues, err := ParseMarksNote(strings.NewReader(`<div id="marks"><div class="week">
+		<div class="dayofweek"><div class="weekday"><h3>Понедельник</h3></div>
+		<table><tbody><tr><td>Физика</td><td></td><td class="col-mark"><span class="mark">5</span></td></tr></tbody></table></div>
+		<div class="dayofweek"><div class="weekday"><h3>Вторник (6 сентябрь 2022 г.)</h3></div>
+		<table><tbody><tr><td>Математика</td><td></td><td class="col-mark"><span class="mark">н</span><span class="mark">4</span><span class="mark">?</span></td></tr></tbody></table></div>
+		</div></div>`), model.CurrentInfo{})
+	if err != nil || len(marks) != 1 || marks[0].CourseName != "Математика" || !marks[0].Date.IsZero() ||
+		!reflect.DeepEqual(marks[0].Grade, []int8{0, 4, 0}) {
+		t.Fatalf("marks %+v (%v)", marks, err)
+	}
+	if !reflect.DeepEqual(reasons(issues), []string{"no lesson date, day skipped", "not a date", "not a grade"}) {
+		t.Errorf("issues - %v", issues)
+	}
+	if issues[0].Row != 0 || issues[2].Row != 1 || issues[2].Raw != "?" || issues[0].Parser != "MarksNote" {
+		t.Errorf("issue rows - %+v", issues)
+	}
+
+	msgs, issues, err := ParseMessages(strings.NewReader(`<div id="content"><div class="pager"><span class="page_remark">Страницы:</span></div>
+		<form><table class="list"><tbody><tr><td><input value="x"/></td><td><a>Тема</a></td><td></td><td>вчера</td></tr></tbody></table></form></div>`))
+	if err != nil || len(msgs) != 1 || msgs[0].Subject != "Тема" ||
+		!reflect.DeepEqual(reasons(issues), []string{"no page count", "not a number", "not a date"}) {
+		t.Errorf("messages %+v - %v (%v)", msgs, issues, err)
+	}
+
+	_, _, issues, _ = ParsePeriodRange(strings.NewReader(`<div id="content"><h3>Оценки</h3></div>`))
+	if !reflect.DeepEqual(reasons(issues), []string{"no period dates"}) {
+		t.Errorf("range - %v", issues)
+	}
+
+	marks, issues, _ = ParseMarksFinal(strings.NewReader(`<div id="marks"><div id="wrap-col"><div id="wrap-marks"><div><div id="mark-row" name="12">
+		<span class="mark itg-q"><a onclick="showMarkItogInfo('1 четверть')">5</a></span>
+		<span class="mark itg-q"><a></a></span></div></div></div></div></div>`), info, nil)
+	if len(marks) != 2 || marks[0].CourseID != 12 || !reflect.DeepEqual(reasons(issues), []string{"unknown course"}) {
+		t.Errorf("final %+v - %v", marks, issues)
+	}
+
+	err = Check(issues)
+	var ie *IssuesError
+	if !errors.As(err, &ie) || len(ie.Issues) != 1 || !strings.Contains(err.Error(), `MarksFinal`) {
+		t.Errorf("strict - %v", err)
+	}
+	if Check(nil) != nil {
+		t.Error("no issues is not an error")
+	}
+}
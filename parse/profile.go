@@ -0,0 +1,313 @@
+// Package parse selector profiles
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v3"
+)
+
+// SelectorProfile holds every CSS selector and onclick pattern the parsers depend on,
+// so a site layout change can be fixed in configuration while waiting for a release.
+// Row selectors are relative to the page, cell selectors are relative to the row.
+//
+// Tags of the fields describe the page for the site diagnosis: in is the field the selector is relative to,
+// of and attr are the element and attribute a pattern is matched against, the text when there is no attr,
+// optional marks elements that may be missing and page lists the pages of a section read from several.
+type SelectorProfile struct {
+	Login    LoginSelectors    `json:"login" yaml:"login"`
+	Info     InfoSelectors     `json:"info" yaml:"info"`
+	Options  OptionSelectors   `json:"options" yaml:"options"`
+	Homework HomeworkSelectors `json:"homework" yaml:"homework"`
+	Teachers TeacherSelectors  `json:"teachers" yaml:"teachers"`
+	Periods  PeriodSelectors   `json:"periods" yaml:"periods"`
+	Note     NoteSelectors     `json:"note" yaml:"note"`
+	List     ListSelectors     `json:"list" yaml:"list"`
+	Final    FinalSelectors    `json:"final" yaml:"final"`
+	Messages MessageSelectors  `json:"messages" yaml:"messages"`
+}
+
+// Pattern is a regular expression, the groups it has to name are listed in the groups tag of the field
+type Pattern string
+
+// LoginSelectors of the login page
+type LoginSelectors struct {
+	Token string `json:"token" yaml:"token"`
+}
+
+// InfoSelectors of the session info on the homework page
+type InfoSelectors struct {
+	Role     string `json:"role" yaml:"role"`
+	EduYear  string `json:"eduYear" yaml:"eduYear"`
+	EduYears string `json:"eduYears" yaml:"eduYears"`
+	// ClassLoader is the element with onload attribute matching LoadSubjects
+	ClassLoader  string  `json:"classLoader" yaml:"classLoader"`
+	LoadSubjects Pattern `json:"loadSubjects" yaml:"loadSubjects" groups:"class" of:"classLoader" attr:"onload"`
+	YearRange    Pattern `json:"yearRange" yaml:"yearRange" groups:"start,end" of:"eduYear"`
+}
+
+// OptionSelectors of regions, schools and courses selectors
+type OptionSelectors struct {
+	Options      string `json:"options" yaml:"options" page:"regions,courses"`
+	Groups       string `json:"groups" yaml:"groups" page:"schools"`
+	GroupOptions string `json:"groupOptions" yaml:"groupOptions" page:"schools" in:"groups"`
+}
+
+// HomeworkSelectors of the homework list
+type HomeworkSelectors struct {
+	Rows     string `json:"rows" yaml:"rows" optional:"true"`
+	Date     string `json:"date" yaml:"date" in:"rows"`
+	Weekday  string `json:"weekday" yaml:"weekday" in:"rows"`
+	Course   string `json:"course" yaml:"course" in:"rows"`
+	Homework string `json:"homework" yaml:"homework" in:"rows"`
+	Subject  string `json:"subject" yaml:"subject" in:"rows"`
+}
+
+// TeacherSelectors of the teachers list
+type TeacherSelectors struct {
+	Rows   string `json:"rows" yaml:"rows"`
+	Mailto string `json:"mailto" yaml:"mailto" in:"rows"`
+	Name   string `json:"name" yaml:"name" in:"rows"`
+	// Course is the bold course name, Courses is the whole cell used when there is none
+	Course  string `json:"course" yaml:"course" in:"rows" optional:"true"`
+	Courses string `json:"courses" yaml:"courses" in:"rows"`
+}
+
+// PeriodSelectors of the marks periods
+type PeriodSelectors struct {
+	Options string  `json:"options" yaml:"options"`
+	Heading string  `json:"heading" yaml:"heading"`
+	Range   Pattern `json:"range" yaml:"range" groups:"start,end" of:"heading"`
+}
+
+// NoteSelectors of the student diary view
+type NoteSelectors struct {
+	Days     string `json:"days" yaml:"days"`
+	Title    string `json:"title" yaml:"title" in:"days"`
+	Rows     string `json:"rows" yaml:"rows" in:"days" optional:"true"`
+	Course   string `json:"course" yaml:"course" in:"rows"`
+	Homework string `json:"homework" yaml:"homework" in:"rows"`
+	Marks    string `json:"marks" yaml:"marks" in:"rows" optional:"true"`
+}
+
+// ListSelectors of the marks list view
+type ListSelectors struct {
+	Rows     string  `json:"rows" yaml:"rows"`
+	Label    string  `json:"label" yaml:"label" in:"rows"`
+	Marks    string  `json:"marks" yaml:"marks" in:"rows" optional:"true"`
+	Average  string  `json:"average" yaml:"average" in:"rows" optional:"true"`
+	Link     string  `json:"link" yaml:"link" in:"marks"`
+	MarkInfo Pattern `json:"markInfo" yaml:"markInfo" groups:"date" of:"link" attr:"onclick"`
+}
+
+// FinalSelectors of quarter and annual marks
+type FinalSelectors struct {
+	Rows    string `json:"rows" yaml:"rows"`
+	Marks   string `json:"marks" yaml:"marks" in:"rows" optional:"true"`
+	Quarter string `json:"quarter" yaml:"quarter" in:"rows" optional:"true"`
+	Annual  string `json:"annual" yaml:"annual" in:"rows" optional:"true"`
+	Link    string `json:"link" yaml:"link" in:"marks"`
+	// ItogInfo of the quarter mark link
+	ItogInfo Pattern `json:"itogInfo" yaml:"itogInfo" groups:"period" of:"link" attr:"onclick"`
+}
+
+// MessageSelectors of the inbox and message pages
+type MessageSelectors struct {
+	PagerRemark string `json:"pagerRemark" yaml:"pagerRemark" page:"messages" optional:"true"`
+	Pages       string `json:"pages" yaml:"pages" page:"messages" optional:"true"`
+	Rows        string `json:"rows" yaml:"rows" page:"messages" optional:"true"`
+	ID          string `json:"id" yaml:"id" page:"messages" in:"rows"`
+	Subject     string `json:"subject" yaml:"subject" page:"messages" in:"rows"`
+	From        string `json:"from" yaml:"from" page:"messages" in:"rows"`
+	Date        string `json:"date" yaml:"date" page:"messages" in:"rows"`
+	ViewDate    string `json:"viewDate" yaml:"viewDate" page:"message"`
+	ViewFrom    string `json:"viewFrom" yaml:"viewFrom" page:"message"`
+	ViewText    string `json:"viewText" yaml:"viewText" page:"message"`
+}
+
+// DefaultProfile matching the current site layout
+func DefaultProfile() SelectorProfile {
+	return SelectorProfile{
+		Login: LoginSelectors{
+			Token: ".login__form > input[name='csrfmiddlewaretoken']",
+		},
+		Info: InfoSelectors{
+			Role:         "#auth_info > #role",
+			EduYear:      "#eduyear > #curedy",
+			EduYears:     "#eduyear",
+			ClassLoader:  "body",
+			LoadSubjects: `loadSubjects\('/ajax/subj/(?P<class>\d+)`,
+			YearRange:    `(?P<start>\d{4})\s*-\s*(?P<end>\d{4})`,
+		},
+		Options: OptionSelectors{
+			Options:      "select > option",
+			Groups:       "select > optgroup",
+			GroupOptions: "option",
+		},
+		Homework: HomeworkSelectors{
+			Rows:     "#homework_list > table.list > tbody > tr",
+			Date:     "td:nth-child(1)",
+			Weekday:  "td:nth-child(2)",
+			Course:   "td:nth-child(3) > a",
+			Homework: "td:nth-child(4)",
+			Subject:  "td:nth-child(5)",
+		},
+		Teachers: TeacherSelectors{
+			Rows:    "#content > table.list > tbody > tr",
+			Mailto:  "td.action_links > a.mailto",
+			Name:    "td:nth-child(2)",
+			Course:  "td:nth-child(3) > b",
+			Courses: "td:nth-child(3)",
+		},
+		Periods: PeriodSelectors{
+			Options: "#mark_range > optgroup > option",
+			Heading: "#content > h3",
+			Range:   `(?P<start>\d{1,2}\s[\p{L}]+\s\d{4}\sг\.) по (?P<end>\d{1,2}\s[\p{L}]+\s\d{4}\sг\.)`,
+		},
+		Note: NoteSelectors{
+			Days:     "#marks > div.week div.dayofweek",
+			Title:    "div.weekday > h3",
+			Rows:     "table tbody > tr",
+			Course:   "td:nth-child(1)",
+			Homework: "td:nth-child(2)",
+			Marks:    "td.col-mark > span.mark",
+		},
+		List: ListSelectors{
+			Rows:     "#marks > #mark-row",
+			Label:    "div.mark-label",
+			Marks:    "span.mark:not(.avg)",
+			Average:  "span.mark.avg",
+			Link:     "a",
+			MarkInfo: `showMarkInfo\('(?P<date>\d{1,2}\s\p{Cyrillic}+\s\d{4}\sг\.)`,
+		},
+		Final: FinalSelectors{
+			Rows:     "#marks > #wrap-col > #wrap-marks > div > #mark-row",
+			Marks:    ".mark",
+			Quarter:  ".itg-q",
+			Annual:   ".itg-y",
+			Link:     "a",
+			ItogInfo: `showMarkItogInfo\('(?P<period>\d\s\p{Cyrillic}*)`,
+		},
+		Messages: MessageSelectors{
+			PagerRemark: "#content > div.pager > span.page_remark",
+			Pages:       "#content > div.pager > span.page",
+			Rows:        "#content > form > table.list > tbody > tr",
+			ID:          "td:nth-child(1) > input",
+			Subject:     "td:nth-child(2) > a",
+			From:        "td:nth-child(3)",
+			Date:        "td:nth-child(4)",
+			ViewDate:    "#msgview > div.msg-meta > div.msg-props > div:nth-child(1)",
+			ViewFrom:    "#msgview > div.msg-meta > div.msg-props > div:nth-child(2) > a:nth-child(2)",
+			ViewText:    "#msgview > div.msg-text",
+		},
+	}
+}
+
+// LoadProfile from YAML or JSON, fields missing from r keep their DefaultProfile values
+func LoadProfile(r io.Reader) (p SelectorProfile, err error) {
+	p = DefaultProfile()
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err = dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return p, fmt.Errorf("selector profile: %w", err)
+	}
+	return p, p.Validate()
+}
+
+// LoadProfileFile from YAML or JSON file
+func LoadProfileFile(path string) (p SelectorProfile, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return LoadProfile(f)
+}
+
+// Validate to check every selector and pattern compiles and patterns name their groups
+func (p *SelectorProfile) Validate() error {
+	var errs []string
+	p.walk(func(name string, value reflect.Value, field reflect.StructField) {
+		s := value.String()
+		if s == "" {
+			errs = append(errs, name+": empty")
+			return
+		}
+		if value.Type() != reflect.TypeOf(Pattern("")) {
+			if _, err := cascadia.Compile(s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+			return
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		for _, g := range strings.Split(field.Tag.Get("groups"), ",") {
+			if re.SubexpIndex(g) < 0 {
+				errs = append(errs, fmt.Sprintf("%s: no (?P<%s>) group", name, g))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("selector profile: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ProfileField is a selector or pattern of the profile
+type ProfileField struct {
+	// Name is the yaml path like note.rows
+	Name    string
+	Value   string
+	Pattern bool
+	Tag     reflect.StructTag
+}
+
+// Fields of the profile in declaration order
+func (p *SelectorProfile) Fields() (fields []ProfileField) {
+	p.walk(func(name string, value reflect.Value, field reflect.StructField) {
+		fields = append(fields, ProfileField{Name: name, Value: value.String(),
+			Pattern: value.Type() == reflect.TypeOf(Pattern("")), Tag: field.Tag})
+	})
+	return
+}
+
+// walk every selector and pattern of the profile, names are yaml paths like note.rows
+func (p *SelectorProfile) walk(fn func(name string, value reflect.Value, field reflect.StructField)) {
+	pages := reflect.ValueOf(p).Elem()
+	for i := 0; i < pages.NumField(); i++ {
+		page := pages.Field(i)
+		for j := 0; j < page.NumField(); j++ {
+			f := page.Type().Field(j)
+			fn(yamlName(pages.Type().Field(i))+"."+yamlName(f), page.Field(j), f)
+		}
+	}
+}
+
+func yamlName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("yaml"), ",")[0]
+}
+
+// compile pattern, idx are the indexes of the named groups
+func (pt Pattern) compile(groups ...string) (re *regexp.Regexp, idx []int, err error) {
+	if re, err = regexp.Compile(string(pt)); err != nil {
+		return
+	}
+	for _, g := range groups {
+		i := re.SubexpIndex(g)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("pattern %q: no (?P<%s>) group", pt, g)
+		}
+		idx = append(idx, i)
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	p, err := LoadProfile(strings.NewReader(`
+note:
+  days: "#diary div.day"
+list:
+  markInfo: showMark\('(?P<date>[^']+)'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := DefaultProfile()
+	if p.Note.Days != "#diary div.day" || p.List.MarkInfo != `showMark\('(?P<date>[^']+)'` || p.Note.Rows != def.Note.Rows || p.Login != def.Login {
+		t.Errorf("profile - %+v", p)
+	}
+
+	p, err = LoadProfile(strings.NewReader(`{"messages": {"viewText": "#msg .text"}}`))
+	if err != nil || p.Messages.ViewText != "#msg .text" || p.Messages.Rows != def.Messages.Rows {
+		t.Errorf("json profile %+v (%v)", p.Messages, err)
+	}
+	if p, err = LoadProfile(strings.NewReader("")); err != nil || p != def {
+		t.Errorf("empty profile (%v)", err)
+	}
+
+	for name, doc := range map[string]string{
+		"unknown field":  "note:\n  weeks: div.week\n",
+		"bad selector":   "note:\n  days: \"div[\"\n",
+		"empty selector": "login:\n  token: \"\"\n",
+		"bad pattern":    "final:\n  itogInfo: \"(\"\n",
+		"no group":       "periods:\n  range: \"с (.+) по (.+)\"\n",
+	} {
+		if _, err = LoadProfile(strings.NewReader(doc)); err == nil {
+			t.Errorf("%s - no error", name)
+		}
+	}
+}
+
+func TestSelectorProfile_Parse(t *testing.T) {
+	p := DefaultProfile()
+	p.List.Rows = "#marks > .course"
+	p.List.MarkInfo = `showMark\('(?P<date>[^']+)'`
+	page := `<div id="marks"><div class="course"><div class="mark-label">Физика</div>
+		<span class="mark"><a onclick="showMark('6 сентября 2022 г.')">3</a></span></div></div>`
+	marks, issues, err := p.ParseMarksList(strings.NewReader(page), info)
+	must(t, issues, err)
+	if len(marks) != 1 || marks[0].CourseName != "Физика" || !marks[0].Date.Equal(day(9, 6)) {
+		t.Errorf("marks - %+v", marks)
+	}
+	if marks, _, _ = ParseMarksList(strings.NewReader(page), info); len(marks) != 0 {
+		t.Errorf("default profile marks - %+v", marks)
+	}
+
+	p.Final.ItogInfo = "("
+	if _, _, err = p.ParseMarksFinal(strings.NewReader(page), info, nil); err == nil {
+		t.Error("invalid pattern is an error")
+	}
+}
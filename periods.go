@@ -0,0 +1,42 @@
+// Package dnevnik76 periods
+package dnevnik76
+
+import "github.com/bvp/dnevnik76-api/model"
+
+type (
+	// PeriodKind of academic period
+	PeriodKind = model.PeriodKind
+	// Gap between two consecutive periods, e.g. holidays
+	Gap = model.Gap
+	// Periods is a list of academic periods ordered by start date
+	Periods = model.Periods
+)
+
+const (
+	// PeriodUnknown is a period that could not be classified
+	PeriodUnknown = model.PeriodUnknown
+	// PeriodQuarter is a quarter (четверть)
+	PeriodQuarter = model.PeriodQuarter
+	// PeriodHalfYear is a half-year (полугодие)
+	PeriodHalfYear = model.PeriodHalfYear
+	// PeriodTrimester is a trimester (триместр)
+	PeriodTrimester = model.PeriodTrimester
+	// PeriodMonth is a calendar month
+	PeriodMonth = model.PeriodMonth
+	// PeriodYear is a whole academic year
+	PeriodYear = model.PeriodYear
+)
+
+// NewPeriods to build ordered periods from the list
+func NewPeriods(list []Lperiod) Periods {
+	return model.NewPeriods(list)
+}
+
+// GetPeriods to get marks periods ordered by start date
+func (cli *Client) GetPeriods() (Periods, error) {
+	periods, err := cli.GetMarksPeriods()
+	if err != nil {
+		return nil, err
+	}
+	return NewPeriods(periods), nil
+}
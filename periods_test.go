@@ -0,0 +1,137 @@
+package dnevnik76
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func testPeriods() Periods {
+	return NewPeriods([]Lperiod{
+		{Name: "2 четверть", Period: "q2", Start: day(2022, time.November, 7), End: day(2022, time.December, 28)},
+		{Name: "1 четверть", Period: "q1", Start: day(2022, time.September, 1), End: day(2022, time.October, 28)},
+		{Name: "Сентябрь", Period: "month9", Start: day(2022, time.September, 1), End: day(2022, time.September, 30)},
+		{Name: "1 полугодие", Period: "h1", Start: day(2022, time.September, 1), End: day(2022, time.December, 28)},
+		{Name: "Учебный год", Period: "year", Start: day(2022, time.September, 1), End: day(2023, time.May, 31)},
+	})
+}
+
+func TestLperiod_Kind(t *testing.T) {
+	want := map[string]PeriodKind{
+		"q1":     PeriodQuarter,
+		"q2":     PeriodQuarter,
+		"month9": PeriodMonth,
+		"h1":     PeriodHalfYear,
+		"year":   PeriodYear,
+	}
+	for _, p := range testPeriods() {
+		if p.Kind() != want[p.Period] {
+			t.Errorf("%s: kind %s, want %s", p.Name, p.Kind(), want[p.Period])
+		}
+	}
+}
+
+func TestPeriods_PeriodAt(t *testing.T) {
+	quarters := testPeriods().OfKind(PeriodQuarter)
+	cases := []struct {
+		date   time.Time
+		period string
+		ok     bool
+	}{
+		{day(2022, time.September, 1), "q1", true},
+		{day(2022, time.October, 28).Add(23 * time.Hour), "q1", true},
+		{day(2022, time.November, 2), "", false},
+		{day(2022, time.November, 7), "q2", true},
+		{day(2022, time.December, 28), "q2", true},
+	}
+	for _, c := range cases {
+		p, ok := quarters.PeriodAt(c.date)
+		if ok != c.ok || p.Period != c.period {
+			t.Errorf("%s: got %q (%t), want %q (%t)", c.date.Format("2006.01.02"), p.Period, ok, c.period, c.ok)
+		}
+	}
+
+	prev, _ := quarters.PreviousBefore(day(2022, time.November, 2))
+	next, _ := quarters.NextAfter(day(2022, time.November, 2))
+	if prev.Period != "q1" || next.Period != "q2" {
+		t.Errorf("previous %q, next %q", prev.Period, next.Period)
+	}
+}
+
+func TestPeriods_PeriodAtMixedTerms(t *testing.T) {
+	terms := testPeriods().Terms()
+	for date, want := range map[time.Time]string{
+		day(2022, time.October, 3):   "q1",
+		day(2022, time.November, 2):  "h1",
+		day(2022, time.November, 20): "q2",
+	} {
+		if p, _ := terms.PeriodAt(date); p.Period != want {
+			t.Errorf("%s: got %q, want %q", date.Format("2006.01.02"), p.Period, want)
+		}
+	}
+}
+
+func TestPeriods_Gaps(t *testing.T) {
+	gaps := testPeriods().OfKind(PeriodQuarter).Gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("gaps - %d, want 1", len(gaps))
+	}
+	g := gaps[0]
+	if !g.Start.Equal(day(2022, time.October, 29)) || !g.End.Equal(day(2022, time.November, 6)) {
+		t.Errorf("gap %s - %s", g.Start.Format("2006.01.02"), g.End.Format("2006.01.02"))
+	}
+	if g.After.Period != "q1" || g.Until.Period != "q2" {
+		t.Errorf("gap between %q and %q", g.After.Period, g.Until.Period)
+	}
+}
+
+func TestClient_GetMarksPeriodsCached(t *testing.T) {
+	var hits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/marks/current/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/marks/current/":
+			fmt.Fprint(w, `<select id="mark_range">
+				<optgroup label="Четверти"><option value="q1">1 четверть</option><option value="q2">2 четверть</option></optgroup>
+				<optgroup label="Месяцы"><option value="month9">Сентябрь</option></optgroup></select>`)
+		case "/marks/current/q1/note":
+			fmt.Fprint(w, `<div id="content"><h3>с 1 сентября 2022 г. по 28 октября 2022 г.</h3></div>`)
+		case "/marks/current/q2/note":
+			fmt.Fprint(w, `<div id="content"><h3>с 7 ноября 2022 г. по 28 декабря 2022 г.</h3></div>`)
+		case "/marks/current/month9/note":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	})
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		mux.ServeHTTP(w, r)
+	}))
+
+	if _, err := cli.GetMarksPeriods(); err == nil {
+		t.Fatal("expected error for failed period page")
+	}
+
+	mux.HandleFunc("/marks/current/month9/note", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="content"><h3>с 1 сентября 2022 г. по 30 сентября 2022 г.</h3></div>`)
+	})
+	atomic.StoreInt32(&hits, 0)
+	periods, err := cli.GetMarksPeriods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 3 || periods[1].Period != "q2" || !periods[1].Start.Equal(day(2022, time.November, 7)) {
+		t.Fatalf("periods - %v", periods)
+	}
+	if _, err = cli.GetMarksPeriods(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&hits); n != 4 {
+		t.Errorf("requests - %d, want 4", n)
+	}
+}
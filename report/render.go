@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// table renders as a titled table
+type table interface {
+	Title() string
+	table() (header []string, rows [][]string)
+}
+
+const legend = "Обозначения: (5) - предварительная оценка, … - период не завершён, — - нет оценки"
+
+func renderMarkdown(w io.Writer, t table) error {
+	header, rows := t.table()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "## %s\n\n", t.Title())
+	line := func(cells []string) {
+		for i := range cells {
+			cells[i] = strings.ReplaceAll(cells[i], "|", `\|`)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	line(append([]string(nil), header...))
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = ":---:"
+	}
+	sep[0] = "---"
+	fmt.Fprintf(&b, "|%s|\n", strings.Join(sep, "|"))
+	for _, r := range rows {
+		line(r)
+	}
+	fmt.Fprintf(&b, "\n%s\n", legend)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+func renderText(w io.Writer, t table) error {
+	header, rows := t.table()
+	widths := make([]int, len(header))
+	for _, r := range append([][]string{header}, rows...) {
+		for i, c := range r {
+			if n := utf8.RuneCountInString(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\n", t.Title())
+	line := func(cells []string) {
+		for i, c := range cells {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c))
+			if i == 0 {
+				b.WriteString(c + pad)
+			} else {
+				b.WriteString("  " + pad + c)
+			}
+		}
+		b.WriteString("\n")
+	}
+	line(header)
+	total := len(widths)*2 - 2
+	for _, wd := range widths {
+		total += wd
+	}
+	b.WriteString(strings.Repeat("-", total) + "\n")
+	for _, r := range rows {
+		line(r)
+	}
+	fmt.Fprintf(&b, "\n%s\n", legend)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("table").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #000; padding: 4px 8px; text-align: center; }
+td:first-child, th:first-child { text-align: left; }
+@media print { body { margin: 0; } }
+</style></head>
+<body>
+<h2>{{.Title}}</h2>
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+<p><small>{{.Legend}}</small></p>
+</body></html>
+`))
+
+func renderHTML(w io.Writer, t table) error {
+	header, rows := t.table()
+	return htmlTemplate.Execute(w, map[string]interface{}{
+		"Title":  t.Title(),
+		"Header": header,
+		"Rows":   rows,
+		"Legend": legend,
+	})
+}
@@ -0,0 +1,228 @@
+// Package report renders report cards and multi-year transcripts
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// CellState of report card cell
+type CellState int
+
+const (
+	// Missing mark for a finished period
+	Missing CellState = iota
+	// Final mark given by school
+	Final
+	// Provisional cell of a period not finished yet, or annual mark computed from terms
+	Provisional
+	// Upcoming period without marks yet
+	Upcoming
+)
+
+// Cell of report card
+type Cell struct {
+	State CellState
+	Grade int8
+	// Average of terms for provisional annual cell
+	Average float64
+}
+
+// Column of report card
+type Column struct {
+	Title   string
+	Quarter int
+	Annual  bool
+	Start   time.Time
+	End     time.Time
+}
+
+// Row of report card
+type Row struct {
+	CourseName string
+	Cells      []Cell
+}
+
+// ReportCard (табель) of final marks, courses by periods
+type ReportCard struct {
+	Student string
+	Class   string
+	SYear   int
+	EYear   int
+	Columns []Column
+	Rows    []Row
+}
+
+// Options of report card
+type Options struct {
+	// Terms of the year, 4 quarters by default
+	Terms int
+	// TermTitle formats term column title
+	TermTitle func(n int) string
+	// Periods give term end dates so unfinished terms become provisional
+	Periods dnevnik76.Periods
+	// Now is the moment provisional cells are computed for, time.Now by default
+	Now time.Time
+}
+
+// NewReportCard to pivot final marks into courses by terms with annual column
+func NewReportCard(marks []dnevnik76.Mark, opts Options) *ReportCard {
+	terms := opts.Periods.FinalTerms()
+	if opts.Terms == 0 {
+		opts.Terms = 4
+		if len(terms) > 0 {
+			opts.Terms = len(terms)
+		}
+	}
+	if opts.TermTitle == nil {
+		opts.TermTitle = func(n int) string { return fmt.Sprintf("%d четверть", n) }
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	rc := &ReportCard{}
+	for q := 1; q <= opts.Terms; q++ {
+		col := Column{Title: opts.TermTitle(q), Quarter: q}
+		if q <= len(terms) {
+			col.Start, col.End = terms[q-1].Start, terms[q-1].End
+		}
+		rc.Columns = append(rc.Columns, col)
+	}
+	annual := Column{Title: "Годовая", Annual: true}
+	if len(terms) > 0 {
+		annual.Start, annual.End = terms[0].Start, terms[len(terms)-1].End
+	}
+	rc.Columns = append(rc.Columns, annual)
+
+	rows := map[string]*Row{}
+	for _, m := range marks {
+		if rc.SYear == 0 {
+			rc.SYear, rc.EYear = m.SYear, m.EYear
+		}
+		course := strings.TrimSpace(m.CourseName)
+		r, ok := rows[course]
+		if !ok {
+			r = &Row{CourseName: course, Cells: make([]Cell, len(rc.Columns))}
+			rows[course] = r
+		}
+		ci := -1
+		switch {
+		case m.Annual:
+			ci = len(rc.Columns) - 1
+		case m.Quarter >= 1 && m.Quarter <= opts.Terms:
+			ci = m.Quarter - 1
+		}
+		if ci >= 0 && len(m.Grade) > 0 && m.Grade[0] > 0 {
+			r.Cells[ci] = Cell{State: Final, Grade: m.Grade[0]}
+		}
+	}
+
+	for _, r := range rows {
+		rc.fill(r, opts.Now)
+		rc.Rows = append(rc.Rows, *r)
+	}
+	sort.Slice(rc.Rows, func(i, j int) bool { return rc.Rows[i].CourseName < rc.Rows[j].CourseName })
+	return rc
+}
+
+// fill cells without final marks
+func (rc *ReportCard) fill(r *Row, now time.Time) {
+	last := len(rc.Columns) - 1
+	var sum, n int
+	for i, c := range rc.Columns[:last] {
+		if r.Cells[i].State == Final {
+			sum += int(r.Cells[i].Grade)
+			n++
+			continue
+		}
+		r.Cells[i].State = state(c, now)
+	}
+	if r.Cells[last].State == Final {
+		return
+	}
+	if n > 0 {
+		avg := float64(sum) / float64(n)
+		r.Cells[last] = Cell{State: Provisional, Grade: int8(math.Floor(avg + 0.5)), Average: avg}
+		return
+	}
+	r.Cells[last].State = state(rc.Columns[last], now)
+}
+
+// state of cell without final mark by period dates
+func state(c Column, now time.Time) CellState {
+	switch {
+	case c.End.IsZero(), now.After(c.End.AddDate(0, 0, 1)):
+		return Missing
+	case now.Before(c.Start):
+		return Upcoming
+	}
+	return Provisional
+}
+
+// Text of cell
+func (c Cell) Text() string {
+	switch c.State {
+	case Final:
+		return fmt.Sprint(c.Grade)
+	case Provisional:
+		if c.Grade == 0 {
+			return "…"
+		}
+		return fmt.Sprintf("(%d)", c.Grade)
+	case Missing:
+		return "—"
+	}
+	return ""
+}
+
+// HTML page of report card ready for printing
+func (rc *ReportCard) HTML(w io.Writer) error {
+	return renderHTML(w, rc)
+}
+
+// Markdown table of report card
+func (rc *ReportCard) Markdown(w io.Writer) error {
+	return renderMarkdown(w, rc)
+}
+
+// Text table of report card for monospace printing
+func (rc *ReportCard) Text(w io.Writer) error {
+	return renderText(w, rc)
+}
+
+// Title of report card
+func (rc *ReportCard) Title() string {
+	title := "Табель успеваемости"
+	if rc.Student != "" {
+		title += ": " + rc.Student
+	}
+	if rc.Class != "" {
+		title += ", " + rc.Class
+	}
+	if rc.SYear > 0 {
+		title += fmt.Sprintf(", %d-%d учебный год", rc.SYear, rc.EYear)
+	}
+	return title
+}
+
+func (rc *ReportCard) table() (header []string, rows [][]string) {
+	header = append(header, "Предмет")
+	for _, c := range rc.Columns {
+		header = append(header, c.Title)
+	}
+	for _, r := range rc.Rows {
+		row := []string{r.CourseName}
+		for _, c := range r.Cells {
+			row = append(row, c.Text())
+		}
+		rows = append(rows, row)
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+var quarters = dnevnik76.NewPeriods([]dnevnik76.Lperiod{
+	{Name: "1 четверть", Start: day(2022, 9, 1), End: day(2022, 10, 28)},
+	{Name: "2 четверть", Start: day(2022, 11, 7), End: day(2022, 12, 28)},
+	{Name: "3 четверть", Start: day(2023, 1, 9), End: day(2023, 3, 24)},
+	{Name: "4 четверть", Start: day(2023, 4, 3), End: day(2023, 5, 26)},
+})
+
+var final = []dnevnik76.Mark{
+	{SYear: 2022, EYear: 2023, CourseName: "Физика", Quarter: 1, Grade: []int8{4}},
+	{SYear: 2022, EYear: 2023, CourseName: "Физика", Quarter: 2, Grade: []int8{5}},
+	{SYear: 2022, EYear: 2023, CourseName: "Алгебра ", Quarter: 2, Grade: []int8{3}},
+	{SYear: 2022, EYear: 2023, CourseName: "Химия", Quarter: 1, Grade: []int8{5}},
+	{SYear: 2022, EYear: 2023, CourseName: "Химия", Annual: true, Grade: []int8{5}},
+}
+
+func TestNewReportCard(t *testing.T) {
+	rc := NewReportCard(final, Options{Periods: quarters, Now: day(2023, 1, 20)})
+	if len(rc.Columns) != 5 || rc.SYear != 2022 {
+		t.Fatalf("columns - %+v", rc.Columns)
+	}
+	want := map[string]string{
+		"Алгебра": "— 3 … (3)",
+		"Физика":  "4 5 … (5)",
+		"Химия":   "5 — … 5",
+	}
+	for _, r := range rc.Rows {
+		var cells []string
+		for _, c := range r.Cells[:3] {
+			cells = append(cells, c.Text())
+		}
+		cells = append(cells, r.Cells[4].Text())
+		if got := strings.Join(cells, " "); got != want[r.CourseName] {
+			t.Errorf("%s - %q, want %q", r.CourseName, got, want[r.CourseName])
+		}
+		if r.Cells[3].State != Upcoming {
+			t.Errorf("%s: 4th quarter - %v", r.CourseName, r.Cells[3].State)
+		}
+	}
+	if phys := rc.Rows[1].Cells[4]; phys.State != Provisional || phys.Average != 4.5 {
+		t.Errorf("provisional annual - %+v", phys)
+	}
+}
+
+func TestNewReportCard_HalfYears(t *testing.T) {
+	periods := dnevnik76.NewPeriods(append([]dnevnik76.Lperiod{
+		{Name: "1 полугодие", Start: day(2022, 9, 1), End: day(2022, 12, 28)},
+		{Name: "2 полугодие", Start: day(2023, 1, 9), End: day(2023, 5, 26)},
+	}, quarters...))
+	rc := NewReportCard(final, Options{Periods: periods, Now: day(2023, 1, 20)})
+	if len(rc.Columns) != 5 || rc.Columns[3].Title != "4 четверть" {
+		t.Fatalf("columns - %+v", rc.Columns)
+	}
+	if c := rc.Columns[1]; !c.Start.Equal(day(2022, 11, 7)) || !c.End.Equal(day(2022, 12, 28)) {
+		t.Errorf("2nd quarter %s - %s", c.Start.Format("2006.01.02"), c.End.Format("2006.01.02"))
+	}
+}
+
+func TestReportCard_Render(t *testing.T) {
+	rc := NewReportCard(final, Options{Periods: quarters, Now: day(2023, 6, 1)})
+	rc.Student = "Петя <Иванов>"
+
+	var b bytes.Buffer
+	if err := rc.Markdown(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "| Физика | 4 | 5 | — | — | (5) |") {
+		t.Errorf("markdown:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := rc.Text(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if len(lines) < 5 || len([]rune(lines[2])) != len([]rune(lines[4])) {
+		t.Errorf("text is not aligned:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := rc.HTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Петя &lt;Иванов&gt;") || !strings.Contains(b.String(), "<td>Химия</td>") {
+		t.Errorf("html:\n%s", b.String())
+	}
+}
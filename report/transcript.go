@@ -0,0 +1,329 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+var reSpaces = regexp.MustCompile(`[\s\p{Zs}]+`)
+
+// Aliases maps course names as they appear on the site to canonical subjects
+type Aliases map[string]string
+
+// DefaultAliases for course names known to drift between years
+var DefaultAliases = Aliases{
+	"алгебра и начала анализа":                 "Алгебра",
+	"алгебра и начала математического анализа": "Алгебра",
+	"физическая культура":                      "Физкультура",
+	"иностранный язык (английский)":            "Английский язык",
+	"английский язык (иностранный)":            "Английский язык",
+	"изобразительное искусство":                "ИЗО",
+	"основы безопасности жизнедеятельности":    "ОБЖ",
+}
+
+// normalize course name for lookup
+func normalize(name string) string {
+	return strings.ToLower(reSpaces.ReplaceAllString(strings.TrimSpace(name), " "))
+}
+
+// LoadAliases from JSON object of canonical subject to its alias list
+func LoadAliases(r io.Reader) (Aliases, error) {
+	var table map[string][]string
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return nil, err
+	}
+	a := Aliases{}
+	for canonical, aliases := range table {
+		a.Add(canonical, aliases...)
+	}
+	return a, nil
+}
+
+// Add aliases of canonical subject
+func (a Aliases) Add(canonical string, aliases ...string) {
+	canonical = reSpaces.ReplaceAllString(strings.TrimSpace(canonical), " ")
+	a[normalize(canonical)] = canonical
+	for _, alias := range aliases {
+		a[normalize(alias)] = canonical
+	}
+}
+
+// Canonical subject of course name, unknown names only get whitespace cleaned
+func (a Aliases) Canonical(name string) string {
+	if c, ok := a[normalize(name)]; ok {
+		return c
+	}
+	return reSpaces.ReplaceAllString(strings.TrimSpace(name), " ")
+}
+
+// FinalSource of final marks for every academic year
+type FinalSource interface {
+	AvailableYears() ([]dnevnik76.EduYear, error)
+	FinalMarks(y dnevnik76.EduYear) ([]dnevnik76.Mark, dnevnik76.CurrentInfo, error)
+}
+
+// ClientSource reads final marks through per-year client views
+type ClientSource struct {
+	Client *dnevnik76.Client
+}
+
+// AvailableYears of the client
+func (s ClientSource) AvailableYears() ([]dnevnik76.EduYear, error) {
+	return s.Client.AvailableYears()
+}
+
+// FinalMarks of academic year y
+func (s ClientSource) FinalMarks(y dnevnik76.EduYear) ([]dnevnik76.Mark, dnevnik76.CurrentInfo, error) {
+	cli, err := s.Client.ForYear(y)
+	if err != nil {
+		return nil, dnevnik76.CurrentInfo{}, err
+	}
+	marks, err := cli.GetMarksFinal()
+	return marks, cli.CurrentInfo, err
+}
+
+// SubjectGrade of one academic year
+type SubjectGrade struct {
+	Subject string
+	// Courses as named on the site
+	Courses []string
+	Terms   []int8
+	Annual  int8
+	// Provisional annual grade computed from terms
+	Provisional bool
+}
+
+// TranscriptYear of one academic year
+type TranscriptYear struct {
+	Year     dnevnik76.EduYear
+	Class    string
+	Subjects []SubjectGrade
+	Average  float64
+}
+
+// SubjectSummary over all years
+type SubjectSummary struct {
+	Subject string
+	// Grades by year in the order of Transcript.Years, 0 when not studied
+	Grades  []int8
+	Average float64
+}
+
+// Transcript of one student across academic years
+type Transcript struct {
+	Student  string
+	Years    []TranscriptYear
+	Subjects []SubjectSummary
+	Average  float64
+}
+
+// TranscriptBuilder collects final marks of every year
+type TranscriptBuilder struct {
+	Source  FinalSource
+	Aliases Aliases
+	Student string
+}
+
+// Build transcript, years go from oldest to newest
+func (b *TranscriptBuilder) Build() (*Transcript, error) {
+	years, err := b.Source.AvailableYears()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(years, func(i, j int) bool { return years[i] < years[j] })
+
+	aliases := b.Aliases
+	if aliases == nil {
+		aliases = DefaultAliases
+	}
+	t := &Transcript{Student: b.Student}
+	for _, y := range years {
+		marks, info, err := b.Source.FinalMarks(y)
+		if err != nil {
+			return nil, fmt.Errorf("final marks %s: %w", y, err)
+		}
+		if ty := transcriptYear(y, info.Class, marks, aliases); len(ty.Subjects) > 0 {
+			t.Years = append(t.Years, ty)
+		}
+	}
+	t.summarize()
+	return t, nil
+}
+
+func transcriptYear(y dnevnik76.EduYear, class string, marks []dnevnik76.Mark, aliases Aliases) TranscriptYear {
+	ty := TranscriptYear{Year: y, Class: class}
+	type acc struct {
+		courses map[string]bool
+		terms   map[int][]int8
+		annual  []int8
+	}
+	subjects := map[string]*acc{}
+	for _, m := range marks {
+		if len(m.Grade) == 0 || m.Grade[0] <= 0 {
+			continue
+		}
+		s := aliases.Canonical(m.CourseName)
+		a, ok := subjects[s]
+		if !ok {
+			a = &acc{courses: map[string]bool{}, terms: map[int][]int8{}}
+			subjects[s] = a
+		}
+		a.courses[strings.TrimSpace(m.CourseName)] = true
+		if m.Annual {
+			a.annual = append(a.annual, m.Grade[0])
+		} else {
+			a.terms[m.Quarter] = append(a.terms[m.Quarter], m.Grade[0])
+		}
+	}
+
+	var sum float64
+	for s, a := range subjects {
+		sg := SubjectGrade{Subject: s}
+		for c := range a.courses {
+			sg.Courses = append(sg.Courses, c)
+		}
+		sort.Strings(sg.Courses)
+		var quarters []int
+		for q := range a.terms {
+			quarters = append(quarters, q)
+		}
+		sort.Ints(quarters)
+		var all []int8
+		for _, q := range quarters {
+			g := round(mean(a.terms[q]))
+			sg.Terms = append(sg.Terms, g)
+			all = append(all, g)
+		}
+		if len(a.annual) > 0 {
+			sg.Annual = round(mean(a.annual))
+		} else {
+			sg.Annual, sg.Provisional = round(mean(all)), true
+		}
+		sum += float64(sg.Annual)
+		ty.Subjects = append(ty.Subjects, sg)
+	}
+	sort.Slice(ty.Subjects, func(i, j int) bool { return ty.Subjects[i].Subject < ty.Subjects[j].Subject })
+	if len(ty.Subjects) > 0 {
+		ty.Average = sum / float64(len(ty.Subjects))
+	}
+	return ty
+}
+
+// summarize subjects over all years
+func (t *Transcript) summarize() {
+	index := map[string]int{}
+	for yi, y := range t.Years {
+		for _, sg := range y.Subjects {
+			i, ok := index[sg.Subject]
+			if !ok {
+				i = len(t.Subjects)
+				index[sg.Subject] = i
+				t.Subjects = append(t.Subjects, SubjectSummary{Subject: sg.Subject, Grades: make([]int8, len(t.Years))})
+			}
+			t.Subjects[i].Grades[yi] = sg.Annual
+		}
+	}
+	var sum float64
+	for i := range t.Subjects {
+		var grades []int8
+		for _, g := range t.Subjects[i].Grades {
+			if g > 0 {
+				grades = append(grades, g)
+			}
+		}
+		t.Subjects[i].Average = mean(grades)
+		sum += t.Subjects[i].Average
+	}
+	sort.Slice(t.Subjects, func(i, j int) bool { return t.Subjects[i].Subject < t.Subjects[j].Subject })
+	if len(t.Subjects) > 0 {
+		t.Average = sum / float64(len(t.Subjects))
+	}
+}
+
+func mean(grades []int8) float64 {
+	if len(grades) == 0 {
+		return 0
+	}
+	var sum int
+	for _, g := range grades {
+		sum += int(g)
+	}
+	return float64(sum) / float64(len(grades))
+}
+
+func round(f float64) int8 {
+	return int8(math.Floor(f + 0.5))
+}
+
+// HTML page of transcript ready for printing
+func (t *Transcript) HTML(w io.Writer) error {
+	return renderHTML(w, t)
+}
+
+// Markdown table of transcript
+func (t *Transcript) Markdown(w io.Writer) error {
+	return renderMarkdown(w, t)
+}
+
+// Text table of transcript for monospace printing
+func (t *Transcript) Text(w io.Writer) error {
+	return renderText(w, t)
+}
+
+// Title of transcript
+func (t *Transcript) Title() string {
+	if t.Student != "" {
+		return "Выписка годовых оценок: " + t.Student
+	}
+	return "Выписка годовых оценок"
+}
+
+func (t *Transcript) table() (header []string, rows [][]string) {
+	header = append(header, "Предмет")
+	for _, y := range t.Years {
+		title := fmt.Sprintf("%d-%d", int(y.Year), int(y.Year)+1)
+		if y.Class != "" {
+			title += " (" + y.Class + ")"
+		}
+		header = append(header, title)
+	}
+	header = append(header, "Средний")
+
+	provisional := map[[2]int]bool{}
+	for yi, y := range t.Years {
+		for _, sg := range y.Subjects {
+			for si, s := range t.Subjects {
+				if s.Subject == sg.Subject && sg.Provisional {
+					provisional[[2]int{si, yi}] = true
+				}
+			}
+		}
+	}
+	for si, s := range t.Subjects {
+		row := []string{s.Subject}
+		for yi, g := range s.Grades {
+			switch {
+			case g == 0:
+				row = append(row, "")
+			case provisional[[2]int{si, yi}]:
+				row = append(row, fmt.Sprintf("(%d)", g))
+			default:
+				row = append(row, fmt.Sprint(g))
+			}
+		}
+		rows = append(rows, append(row, fmt.Sprintf("%.2f", s.Average)))
+	}
+	summary := []string{"Средний балл"}
+	for _, y := range t.Years {
+		summary = append(summary, fmt.Sprintf("%.2f", y.Average))
+	}
+	rows = append(rows, append(summary, fmt.Sprintf("%.2f", t.Average)))
+	return
+}
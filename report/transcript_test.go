@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+type fakeFinalSource map[dnevnik76.EduYear][]dnevnik76.Mark
+
+func (f fakeFinalSource) AvailableYears() ([]dnevnik76.EduYear, error) {
+	return []dnevnik76.EduYear{2022, 2021}, nil
+}
+
+func (f fakeFinalSource) FinalMarks(y dnevnik76.EduYear) ([]dnevnik76.Mark, dnevnik76.CurrentInfo, error) {
+	return f[y], dnevnik76.CurrentInfo{Class: map[dnevnik76.EduYear]string{2021: "9А", 2022: "10А"}[y]}, nil
+}
+
+func TestAliases(t *testing.T) {
+	a, err := LoadAliases(strings.NewReader(`{"История": ["История России", "Всеобщая история"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"История  России ": "История",
+		"всеобщая история": "История",
+		"Физика ":          "Физика",
+		"Алгебра":          "Алгебра",
+	}
+	for name, want := range cases {
+		if got := a.Canonical(name); got != want {
+			t.Errorf("%q - %q, want %q", name, got, want)
+		}
+	}
+	if got := DefaultAliases.Canonical("Алгебра и  начала анализа"); got != "Алгебра" {
+		t.Errorf("default alias - %q", got)
+	}
+}
+
+func TestTranscriptBuilder_Build(t *testing.T) {
+	src := fakeFinalSource{
+		2021: {
+			{CourseName: "Алгебра ", Quarter: 1, Grade: []int8{4}},
+			{CourseName: "Алгебра ", Annual: true, Grade: []int8{4}},
+			{CourseName: "Физика", Annual: true, Grade: []int8{5}},
+		},
+		2022: {
+			{CourseName: "Алгебра и начала анализа", Quarter: 1, Grade: []int8{5}},
+			{CourseName: "Алгебра и начала анализа", Quarter: 2, Grade: []int8{4}},
+			{CourseName: "Физика", Annual: true, Grade: []int8{4}},
+		},
+	}
+	b := &TranscriptBuilder{Source: src, Student: "Петя"}
+	tr, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Years) != 2 || tr.Years[0].Year != 2021 || tr.Years[1].Class != "10А" {
+		t.Fatalf("years - %+v", tr.Years)
+	}
+	if len(tr.Subjects) != 2 {
+		t.Fatalf("subjects - %+v", tr.Subjects)
+	}
+	alg := tr.Subjects[0]
+	if alg.Subject != "Алгебра" || alg.Grades[0] != 4 || alg.Grades[1] != 5 || alg.Average != 4.5 {
+		t.Errorf("algebra - %+v", alg)
+	}
+	if sg := tr.Years[1].Subjects[0]; !sg.Provisional || sg.Courses[0] != "Алгебра и начала анализа" {
+		t.Errorf("provisional algebra - %+v", sg)
+	}
+	if tr.Years[0].Average != 4.5 || tr.Average != 4.5 {
+		t.Errorf("averages - %v, %v", tr.Years[0].Average, tr.Average)
+	}
+
+	var buf bytes.Buffer
+	tr.Markdown(&buf)
+	for _, want := range []string{"| Предмет | 2021-2022 (9А) | 2022-2023 (10А) | Средний |", "| Алгебра | 4 | (5) | 4.50 |", "| Средний балл | 4.50 | 4.50 | 4.50 |"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("no %q in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package snapshot
+
+import (
+	"fmt"
+	"reflect"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// Op of change
+type Op int
+
+const (
+	// Added record
+	Added Op = iota + 1
+	// Removed record
+	Removed
+	// Modified record, e.g. a corrected mark
+	Modified
+)
+
+func (o Op) String() string {
+	return [...]string{"", "added", "removed", "modified"}[o]
+}
+
+// MarshalText to encode op by name
+func (o Op) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText to decode op by name
+func (o *Op) UnmarshalText(text []byte) error {
+	for _, op := range []Op{Added, Removed, Modified} {
+		if op.String() == string(text) {
+			*o = op
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown op %q", text)
+}
+
+// Change of one record
+type Change[T any] struct {
+	Op  Op     `json:"op"`
+	Key string `json:"key"`
+	Old *T     `json:"old,omitempty"`
+	New *T     `json:"new,omitempty"`
+}
+
+// MarkChange of a mark or a final mark
+type MarkChange = Change[dnevnik76.Mark]
+
+// HomeworkChange of a homework
+type HomeworkChange = Change[dnevnik76.Homework]
+
+// MessageChange of a message
+type MessageChange = Change[dnevnik76.Message]
+
+// Changes between two snapshots
+type Changes struct {
+	Marks    []MarkChange     `json:"marks"`
+	Final    []MarkChange     `json:"final"`
+	Homework []HomeworkChange `json:"homework"`
+	Messages []MessageChange  `json:"messages"`
+}
+
+// Empty reports whether there are no changes
+func (c Changes) Empty() bool {
+	return len(c.Marks)+len(c.Final)+len(c.Homework)+len(c.Messages) == 0
+}
+
+// Diff to compare two snapshots.
+// Marks removed from an older marks period are not reported.
+func Diff(old, cur *Snapshot) (c Changes) {
+	c.Marks = diff(old.Marks, cur.Marks, markKeys, sameGrades)
+	if old.MarksPeriod != cur.MarksPeriod {
+		c.Marks = withoutRemoved(c.Marks)
+	}
+	c.Final = diff(old.Final, cur.Final, markKeys, sameGrades)
+	c.Homework = diff(old.Homework, cur.Homework, homeworkKeys, func(a, b dnevnik76.Homework) bool {
+		return a.Homework == b.Homework && a.Subject == b.Subject
+	})
+	c.Messages = diff(old.Messages, cur.Messages, messageKeys, func(a, b dnevnik76.Message) bool {
+		return a.Subject == b.Subject && a.From == b.From
+	})
+	return
+}
+
+// markKeys by Mark.Key, see dnevnik76.Positions
+func markKeys(marks []dnevnik76.Mark) []string {
+	pos := dnevnik76.Positions(marks, dnevnik76.Mark.Key, func(m *dnevnik76.Mark) *int { return &m.Position })
+	keys := make([]string, len(marks))
+	for i, m := range marks {
+		m.Position = pos[i]
+		keys[i] = m.Key()
+	}
+	return keys
+}
+
+// homeworkKeys by Homework.Key, see dnevnik76.Positions
+func homeworkKeys(hws []dnevnik76.Homework) []string {
+	pos := dnevnik76.Positions(hws, dnevnik76.Homework.Key, func(h *dnevnik76.Homework) *int { return &h.Position })
+	keys := make([]string, len(hws))
+	for i, h := range hws {
+		h.Position = pos[i]
+		keys[i] = h.Key()
+	}
+	return keys
+}
+
+func messageKeys(msgs []dnevnik76.Message) []string {
+	keys := make([]string, len(msgs))
+	for i, m := range msgs {
+		keys[i] = m.Key()
+	}
+	return keys
+}
+
+func sameGrades(a, b dnevnik76.Mark) bool {
+	return reflect.DeepEqual(a.Grade, b.Grade)
+}
+
+// diff of records identified by their natural keys
+func diff[T any](old, cur []T, keys func([]T) []string, equal func(a, b T) bool) (changes []Change[T]) {
+	oldKeys, curKeys := keys(old), keys(cur)
+	prev := make(map[string]int, len(old))
+	for i, k := range oldKeys {
+		prev[k] = i
+	}
+	seen := make(map[string]bool, len(cur))
+	for i, k := range curKeys {
+		seen[k] = true
+		n := &cur[i]
+		j, ok := prev[k]
+		if !ok {
+			changes = append(changes, Change[T]{Op: Added, Key: k, New: n})
+			continue
+		}
+		if o := &old[j]; !equal(*o, *n) {
+			changes = append(changes, Change[T]{Op: Modified, Key: k, Old: o, New: n})
+		}
+	}
+	for i, k := range oldKeys {
+		if !seen[k] {
+			changes = append(changes, Change[T]{Op: Removed, Key: k, Old: &old[i]})
+		}
+	}
+	return
+}
+
+func withoutRemoved(changes []MarkChange) (result []MarkChange) {
+	for _, c := range changes {
+		if c.Op != Removed {
+			result = append(result, c)
+		}
+	}
+	return
+}
@@ -0,0 +1,149 @@
+// Package snapshot detects changes of diary records between runs.
+//
+// It is the incremental sync engine, named snapshot rather than sync
+// so it does not shadow the standard sync package in the importing code.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// Source of diary records, usually *dnevnik76.Client
+type Source interface {
+	GetCurrentQuarter() string
+	GetMarksFor(p string) ([]dnevnik76.Mark, error)
+	GetMarksFinal() ([]dnevnik76.Mark, error)
+	GetHomework() ([]dnevnik76.Homework, error)
+	GetMessages() ([]dnevnik76.Message, error)
+}
+
+// Snapshot of diary records at some moment
+type Snapshot struct {
+	TakenAt     time.Time            `json:"takenAt"`
+	MarksPeriod string               `json:"marksPeriod"`
+	Marks       []dnevnik76.Mark     `json:"marks"`
+	Final       []dnevnik76.Mark     `json:"final"`
+	Homework    []dnevnik76.Homework `json:"homework"`
+	Messages    []dnevnik76.Message  `json:"messages"`
+}
+
+// Store keeps the last snapshot between runs
+type Store interface {
+	// Load returns nil snapshot when nothing was saved yet
+	Load() (*Snapshot, error)
+	Save(s *Snapshot) error
+}
+
+// FileStore keeps snapshot in a JSON file
+type FileStore struct {
+	Path string
+}
+
+// Load snapshot from file
+func (fs FileStore) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(fs.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{}
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save snapshot to file
+func (fs FileStore) Save(s *Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := fs.Path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fs.Path)
+}
+
+// Syncer to fetch current records and compare them with the stored snapshot
+type Syncer struct {
+	Source Source
+	Store  Store
+}
+
+// New syncer
+func New(src Source, store Store) *Syncer {
+	return &Syncer{Source: src, Store: store}
+}
+
+// Fetch current snapshot from source
+func (s *Syncer) Fetch(ctx context.Context) (snap *Snapshot, err error) {
+	snap = &Snapshot{TakenAt: time.Now(), MarksPeriod: s.Source.GetCurrentQuarter()}
+	steps := []func() error{
+		func() (err error) {
+			var marks []dnevnik76.Mark
+			marks, err = s.Source.GetMarksFor(snap.MarksPeriod)
+			snap.Marks = graded(marks)
+			return
+		},
+		func() (err error) {
+			var marks []dnevnik76.Mark
+			marks, err = s.Source.GetMarksFinal()
+			snap.Final = graded(marks)
+			return
+		},
+		func() (err error) {
+			snap.Homework, err = s.Source.GetHomework()
+			return
+		},
+		func() (err error) {
+			snap.Messages, err = s.Source.GetMessages()
+			return
+		},
+	}
+	for _, f := range steps {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = f(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Run to fetch current records, save them and return changes since the last run.
+// The first run only stores the snapshot and reports no changes.
+func (s *Syncer) Run(ctx context.Context) (changes Changes, err error) {
+	prev, err := s.Store.Load()
+	if err != nil {
+		return
+	}
+	cur, err := s.Fetch(ctx)
+	if err != nil {
+		return
+	}
+	if prev != nil {
+		changes = Diff(prev, cur)
+	}
+	err = s.Store.Save(cur)
+	return
+}
+
+// graded to drop lessons without marks
+func graded(marks []dnevnik76.Mark) (result []dnevnik76.Mark) {
+	for _, m := range marks {
+		if len(m.Grade) > 0 {
+			result = append(result, m)
+		}
+	}
+	return
+}
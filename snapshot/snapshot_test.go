@@ -0,0 +1,111 @@
+package snapshot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+type fakeSource struct {
+	period   string
+	marks    []dnevnik76.Mark
+	final    []dnevnik76.Mark
+	homework []dnevnik76.Homework
+	messages []dnevnik76.Message
+}
+
+func (f *fakeSource) GetCurrentQuarter() string { return f.period }
+func (f *fakeSource) GetMarksFor(p string) ([]dnevnik76.Mark, error) {
+	return f.marks, nil
+}
+func (f *fakeSource) GetMarksFinal() ([]dnevnik76.Mark, error)   { return f.final, nil }
+func (f *fakeSource) GetHomework() ([]dnevnik76.Homework, error) { return f.homework, nil }
+func (f *fakeSource) GetMessages() ([]dnevnik76.Message, error)  { return f.messages, nil }
+
+var monday = time.Date(2022, time.September, 5, 0, 0, 0, 0, time.Local)
+
+func mark(course string, date time.Time, grades ...int8) dnevnik76.Mark {
+	return dnevnik76.Mark{UserID: "08331111", SchoolID: 760215, SYear: 2022, CourseName: course, Date: date, Grade: grades}
+}
+
+func TestSyncer_Run(t *testing.T) {
+	src := &fakeSource{
+		period: "q1",
+		marks: []dnevnik76.Mark{
+			mark("Математика", monday, 5),
+			mark("Математика", monday, 4),
+			mark("Русский язык", monday),
+			mark("Физика", monday, 3),
+		},
+		final:    []dnevnik76.Mark{{UserID: "08331111", CourseID: 11, Quarter: 1, Grade: []int8{4}}},
+		homework: []dnevnik76.Homework{{SchoolID: 760215, ClassID: 121, Date: monday, CourseName: "Математика", Homework: "№ 1"}},
+		messages: []dnevnik76.Message{{UserID: "08331111", ID: 7, Subject: "Собрание"}},
+	}
+	s := New(src, FileStore{Path: filepath.Join(t.TempDir(), "snapshot.json")})
+
+	changes, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Fatalf("first run changes - %+v", changes)
+	}
+
+	src.marks = []dnevnik76.Mark{
+		mark("Математика", monday, 5),
+		mark("Математика", monday, 5),
+		mark("Русский язык", monday, 4),
+	}
+	src.final = append(src.final, dnevnik76.Mark{UserID: "08331111", CourseID: 11, Annual: true, Grade: []int8{5}})
+	src.homework[0].Homework = "№ 1, 2"
+	src.messages = nil
+
+	changes, err = s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]Op{}
+	for _, c := range changes.Marks {
+		got[c.Key] = c.Op
+	}
+	want := map[string]Op{
+		"mark/08331111/760215/2022/lesson/Математика/2022-09-05/1":   Modified,
+		"mark/08331111/760215/2022/lesson/Русский язык/2022-09-05/0": Added,
+		"mark/08331111/760215/2022/lesson/Физика/2022-09-05/0":       Removed,
+	}
+	if len(got) != len(want) {
+		t.Errorf("mark changes - %v", got)
+	}
+	for k, op := range want {
+		if got[k] != op {
+			t.Errorf("%s: %s, want %s", k, got[k], op)
+		}
+	}
+	for _, c := range changes.Marks {
+		if c.Op == Modified && (c.Old.Grade[0] != 4 || c.New.Grade[0] != 5) {
+			t.Errorf("modified mark %v -> %v", c.Old.Grade, c.New.Grade)
+		}
+	}
+	if len(changes.Final) != 1 || changes.Final[0].Op != Added || !changes.Final[0].New.Annual {
+		t.Errorf("final changes - %+v", changes.Final)
+	}
+	if len(changes.Homework) != 1 || changes.Homework[0].Op != Modified {
+		t.Errorf("homework changes - %+v", changes.Homework)
+	}
+	if len(changes.Messages) != 1 || changes.Messages[0].Op != Removed || changes.Messages[0].Key != "message/08331111/7" {
+		t.Errorf("message changes - %+v", changes.Messages)
+	}
+}
+
+func TestDiff_NewPeriod(t *testing.T) {
+	old := &Snapshot{MarksPeriod: "q1", Marks: []dnevnik76.Mark{mark("Физика", monday, 3)}}
+	cur := &Snapshot{MarksPeriod: "q2", Marks: []dnevnik76.Mark{mark("Физика", monday.AddDate(0, 2, 0), 5)}}
+	changes := Diff(old, cur)
+	if len(changes.Marks) != 1 || changes.Marks[0].Op != Added {
+		t.Errorf("mark changes - %+v", changes.Marks)
+	}
+}
@@ -0,0 +1,238 @@
+// Package store mark history
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// ChangeKind of mark version
+type ChangeKind int
+
+const (
+	// NewGrade is a grade seen for the first time, including one more grade for a lesson
+	NewGrade ChangeKind = iota + 1
+	// AlteredGrade is a grade changed by the teacher
+	AlteredGrade
+	// RemovedGrade is a grade deleted by the teacher
+	RemovedGrade
+)
+
+func (k ChangeKind) String() string {
+	return [...]string{"", "new", "altered", "removed"}[k]
+}
+
+// MarshalText to encode kind by name
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText to decode kind by name
+func (k *ChangeKind) UnmarshalText(text []byte) error {
+	for _, kind := range []ChangeKind{NewGrade, AlteredGrade, RemovedGrade} {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown change kind %q", text)
+}
+
+// MarkVersion is one state of a mark, kept until the mark changes
+type MarkVersion struct {
+	ID   int64      `json:"id"`
+	Key  string     `json:"key"`
+	Kind ChangeKind `json:"kind"`
+	// Mark as seen, without grades for a removed one
+	Mark dnevnik76.Mark `json:"mark"`
+	// Previous grades for altered and removed versions
+	Previous  []int8    `json:"previous,omitempty"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// RecordMarks to add versions of marks seen at the moment.
+// Scope is what the scrape covered, e.g. the period dates and the user:
+// marks stored within scope and missing from marks are recorded as removed.
+// Lessons without grades count as missing. New versions are returned.
+func (s *Store) RecordMarks(ctx context.Context, at time.Time, scope Query, marks []dnevnik76.Mark) (changes []MarkVersion, err error) {
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		latest, err := latestVersions(ctx, tx, scope)
+		if err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		pos := markPositions(marks)
+		for i, m := range marks {
+			if len(m.Grade) == 0 {
+				continue
+			}
+			m.Position = pos[i]
+			key := m.Key()
+			seen[key] = true
+			prev, ok := latest[key]
+			if !ok {
+				if prev, ok, err = latestVersion(ctx, tx, key); err != nil {
+					return err
+				}
+			}
+			v := MarkVersion{Key: key, Mark: m, FirstSeen: at, LastSeen: at}
+			switch {
+			case !ok || gone(prev):
+				v.Kind = NewGrade
+			case reflect.DeepEqual(prev.Mark.Grade, m.Grade):
+				if _, err = tx.ExecContext(ctx, "UPDATE mark_versions SET last_seen = ? WHERE id = ?", unix(at), prev.ID); err != nil {
+					return err
+				}
+				continue
+			case isPrefix(prev.Mark.Grade, m.Grade):
+				v.Kind, v.Previous = NewGrade, prev.Mark.Grade
+			case isPrefix(m.Grade, prev.Mark.Grade):
+				v.Kind, v.Previous = RemovedGrade, prev.Mark.Grade
+			default:
+				v.Kind, v.Previous = AlteredGrade, prev.Mark.Grade
+			}
+			if err = insertVersion(ctx, tx, &v); err != nil {
+				return err
+			}
+			changes = append(changes, v)
+		}
+		for key, prev := range latest {
+			if seen[key] || gone(prev) {
+				continue
+			}
+			v := MarkVersion{Key: key, Kind: RemovedGrade, Mark: prev.Mark, Previous: prev.Mark.Grade, FirstSeen: at, LastSeen: at}
+			v.Mark.Grade = nil
+			if err = insertVersion(ctx, tx, &v); err != nil {
+				return err
+			}
+			changes = append(changes, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// MarkHistory of marks matching query, every version ordered by lesson date and time seen
+func (s *Store) MarkHistory(ctx context.Context, q Query) (versions []MarkVersion, err error) {
+	where, args := q.where(true)
+	err = s.query(ctx, selectVersions+where+` ORDER BY date, course_name, mark_key, id`, args, func(rows *sql.Rows) error {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, v)
+		return nil
+	})
+	return
+}
+
+// CourseTimeline of every mark version of user in course
+func (s *Store) CourseTimeline(ctx context.Context, userID, course string) ([]MarkVersion, error) {
+	return s.MarkHistory(ctx, Query{UserID: userID, Course: course})
+}
+
+// LessonTimeline of every mark version of user for lessons on date
+func (s *Store) LessonTimeline(ctx context.Context, userID string, date time.Time) ([]MarkVersion, error) {
+	return s.MarkHistory(ctx, Query{UserID: userID, From: date, To: date})
+}
+
+const selectVersions = `SELECT id, mark_key, kind, mark, first_seen, last_seen FROM mark_versions`
+
+// latestVersions of every mark within scope
+func latestVersions(ctx context.Context, tx *sql.Tx, scope Query) (map[string]MarkVersion, error) {
+	conds, args := scope.conds(true)
+	conds = append([]string{"id IN (SELECT max(id) FROM mark_versions GROUP BY mark_key)"}, conds...)
+	rows, err := tx.QueryContext(ctx, selectVersions+" WHERE "+strings.Join(conds, " AND "), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	latest := map[string]MarkVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		latest[v.Key] = v
+	}
+	return latest, rows.Err()
+}
+
+// gone reports whether version records the mark removed entirely, a partial removal keeps the rest of the grades
+func gone(v MarkVersion) bool {
+	return len(v.Mark.Grade) == 0
+}
+
+// latestVersion of the mark outside of scope, e.g. moved to another date
+func latestVersion(ctx context.Context, tx *sql.Tx, key string) (v MarkVersion, ok bool, err error) {
+	rows, err := tx.QueryContext(ctx, selectVersions+" WHERE mark_key = ? ORDER BY id DESC LIMIT 1", key)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if rows.Next() {
+		v, err = scanVersion(rows)
+		ok = err == nil
+		return
+	}
+	err = rows.Err()
+	return
+}
+
+func insertVersion(ctx context.Context, tx *sql.Tx, v *MarkVersion) error {
+	data, err := json.Marshal(versionData{Mark: v.Mark, Previous: v.Previous})
+	if err != nil {
+		return err
+	}
+	m := v.Mark
+	return tx.QueryRowContext(ctx, `INSERT INTO mark_versions (mark_key, user_id, school_id, course_id, course_name,
+			date, kind, mark, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		v.Key, m.UserID, m.SchoolID, m.CourseID, strings.TrimSpace(m.CourseName), unix(m.Date),
+		v.Kind, string(data), unix(v.FirstSeen), unix(v.LastSeen)).Scan(&v.ID)
+}
+
+// versionData is kept as JSON in the mark column
+type versionData struct {
+	Mark     dnevnik76.Mark `json:"mark"`
+	Previous []int8         `json:"previous,omitempty"`
+}
+
+func scanVersion(rows *sql.Rows) (v MarkVersion, err error) {
+	var data string
+	var first, last int64
+	if err = rows.Scan(&v.ID, &v.Key, &v.Kind, &data, &first, &last); err != nil {
+		return
+	}
+	var vd versionData
+	if err = json.Unmarshal([]byte(data), &vd); err != nil {
+		return
+	}
+	v.Mark, v.Previous = vd.Mark, vd.Previous
+	v.FirstSeen, v.LastSeen = fromUnix(first), fromUnix(last)
+	return
+}
+
+// isPrefix reports whether a is a proper prefix of b
+func isPrefix(a, b []int8) bool {
+	if len(a) >= len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func TestStore_RecordMarks(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, ":memory:")
+	scope := Query{UserID: "u", From: day(9, 1), To: day(9, 30)}
+	mark := func(course string, date time.Time, grades ...int8) dnevnik76.Mark {
+		return dnevnik76.Mark{UserID: "u", SchoolID: 1, SYear: 2022, CourseName: course, Date: date, Grade: grades}
+	}
+	at := func(h int) time.Time {
+		return time.Date(2022, time.September, 20, h, 0, 0, 0, time.Local)
+	}
+	record := func(h int, marks ...dnevnik76.Mark) map[string]ChangeKind {
+		changes, err := s.RecordMarks(ctx, at(h), scope, marks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds := map[string]ChangeKind{}
+		for _, c := range changes {
+			kinds[c.Mark.CourseName] = c.Kind
+		}
+		return kinds
+	}
+
+	if got := record(8, mark("Алгебра", day(9, 5), 4), mark("Физика", day(9, 6), 3), mark("Химия", day(9, 7))); !reflect.DeepEqual(got,
+		map[string]ChangeKind{"Алгебра": NewGrade, "Физика": NewGrade}) {
+		t.Errorf("first scrape - %v", got)
+	}
+	if got := record(9, mark("Алгебра", day(9, 5), 4), mark("Физика", day(9, 6), 3)); len(got) != 0 {
+		t.Errorf("same scrape - %v", got)
+	}
+	if got := record(10, mark("Алгебра", day(9, 5), 5), mark("Физика", day(9, 6), 3, 4)); !reflect.DeepEqual(got,
+		map[string]ChangeKind{"Алгебра": AlteredGrade, "Физика": NewGrade}) {
+		t.Errorf("corrections - %v", got)
+	}
+	if got := record(11, mark("Физика", day(9, 6), 3, 4), mark("Химия", day(10, 3), 5)); !reflect.DeepEqual(got,
+		map[string]ChangeKind{"Алгебра": RemovedGrade, "Химия": NewGrade}) {
+		t.Errorf("removal - %v", got)
+	}
+
+	algebra, err := s.CourseTimeline(ctx, "u", "Алгебра")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(algebra) != 3 {
+		t.Fatalf("algebra timeline - %+v", algebra)
+	}
+	first, altered, removed := algebra[0], algebra[1], algebra[2]
+	if !first.FirstSeen.Equal(at(8)) || !first.LastSeen.Equal(at(9)) {
+		t.Errorf("first version seen %s - %s", first.FirstSeen, first.LastSeen)
+	}
+	if altered.Kind != AlteredGrade || !reflect.DeepEqual(altered.Previous, []int8{4}) || altered.Mark.Grade[0] != 5 {
+		t.Errorf("altered - %+v", altered)
+	}
+	if removed.Kind != RemovedGrade || removed.Mark.Grade != nil || !reflect.DeepEqual(removed.Previous, []int8{5}) {
+		t.Errorf("removed - %+v", removed)
+	}
+	if first.Key != removed.Key || first.Key != first.Mark.Key() {
+		t.Errorf("keys %q, %q", first.Key, removed.Key)
+	}
+
+	if got := record(12, mark("Физика", day(9, 6), 3), mark("Химия", day(10, 3), 5)); !reflect.DeepEqual(got,
+		map[string]ChangeKind{"Физика": RemovedGrade}) {
+		t.Errorf("partial removal - %v", got)
+	}
+	if got := record(13, mark("Физика", day(9, 6), 3), mark("Химия", day(10, 3), 5)); len(got) != 0 {
+		t.Errorf("same scrape after partial removal - %v", got)
+	}
+	if got := record(14, mark("Химия", day(10, 3), 5)); !reflect.DeepEqual(got,
+		map[string]ChangeKind{"Физика": RemovedGrade}) {
+		t.Errorf("removal after partial removal - %v", got)
+	}
+
+	lesson, err := s.LessonTimeline(ctx, "u", day(9, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lesson) != 4 || lesson[1].Kind != NewGrade || !reflect.DeepEqual(lesson[1].Previous, []int8{3}) ||
+		!reflect.DeepEqual(lesson[2].Mark.Grade, []int8{3}) || lesson[3].Mark.Grade != nil || !reflect.DeepEqual(lesson[3].Previous, []int8{3}) {
+		t.Errorf("lesson timeline - %+v", lesson)
+	}
+}
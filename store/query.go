@@ -0,0 +1,223 @@
+// Package store queries
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// Query of dated records, zero fields are not filtered on
+type Query struct {
+	// From is the first day included
+	From time.Time
+	// To is the last day included
+	To time.Time
+	// Course name, matched ignoring surrounding spaces
+	Course   string
+	CourseID int64
+	UserID   string
+	SchoolID int64
+}
+
+// where clause and arguments of the query, date column is named date
+func (q Query) where(userColumn bool) (string, []interface{}) {
+	conds, args := q.conds(userColumn)
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (q Query) conds(userColumn bool) (conds []string, args []interface{}) {
+	if !q.From.IsZero() {
+		conds = append(conds, "date >= ?")
+		args = append(args, unix(dayStart(q.From)))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, "date < ?")
+		args = append(args, unix(dayStart(q.To).AddDate(0, 0, 1)))
+	}
+	if q.Course != "" {
+		conds = append(conds, "trim(course_name) = ?")
+		args = append(args, strings.TrimSpace(q.Course))
+	}
+	if q.CourseID != 0 {
+		conds = append(conds, "course_id = ?")
+		args = append(args, q.CourseID)
+	}
+	if q.UserID != "" && userColumn {
+		conds = append(conds, "user_id = ?")
+		args = append(args, q.UserID)
+	}
+	if q.SchoolID != 0 {
+		conds = append(conds, "school_id = ?")
+		args = append(args, q.SchoolID)
+	}
+	return
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Marks matching query ordered by date
+func (s *Store) Marks(ctx context.Context, q Query) (marks []dnevnik76.Mark, err error) {
+	where, args := q.where(true)
+	err = s.query(ctx, `SELECT id, user_id, school_id, course_id, course_name, subject, homework,
+		grades, dow, date, s_year, e_year, quarter, term, annual, position FROM marks`+where+` ORDER BY date, course_name, position`,
+		args, func(rows *sql.Rows) (err error) {
+			var m dnevnik76.Mark
+			var grades string
+			var date int64
+			if err = rows.Scan(&m.ID, &m.UserID, &m.SchoolID, &m.CourseID, &m.CourseName, &m.Subject, &m.HomeWork,
+				&grades, &m.DayOfWeek, &date, &m.SYear, &m.EYear, &m.Quarter, &m.Term, &m.Annual, &m.Position); err != nil {
+				return
+			}
+			if m.Grade, err = parseGrades(grades); err != nil {
+				return
+			}
+			m.Date = fromUnix(date)
+			marks = append(marks, m)
+			return
+		})
+	return
+}
+
+// Homework matching query ordered by date, UserID is ignored
+func (s *Store) Homework(ctx context.Context, q Query) (hws []dnevnik76.Homework, err error) {
+	where, args := q.where(false)
+	err = s.query(ctx, `SELECT id, school_id, class_id, date, dow, course_id, course_name,
+		homework, subject, position FROM homework`+where+` ORDER BY date, course_name, position`,
+		args, func(rows *sql.Rows) error {
+			var h dnevnik76.Homework
+			var date int64
+			if err := rows.Scan(&h.ID, &h.SchoolID, &h.ClassID, &date, &h.DayOfWeek, &h.CourseID, &h.CourseName,
+				&h.Homework, &h.Subject, &h.Position); err != nil {
+				return err
+			}
+			h.Date = fromUnix(date)
+			hws = append(hws, h)
+			return nil
+		})
+	return
+}
+
+// Messages matching query, newest first, course and school are ignored
+func (s *Store) Messages(ctx context.Context, q Query) (msgs []dnevnik76.Message, err error) {
+	where, args := Query{From: q.From, To: q.To, UserID: q.UserID}.where(true)
+	err = s.query(ctx, `SELECT id, user_id, date, sender, is_unread, subject, body
+		FROM messages`+where+` ORDER BY date DESC, id DESC`, args, func(rows *sql.Rows) error {
+		var m dnevnik76.Message
+		var date int64
+		if err := rows.Scan(&m.ID, &m.UserID, &date, &m.From, &m.IsUnread, &m.Subject, &m.Body); err != nil {
+			return err
+		}
+		m.Date = fromUnix(date)
+		msgs = append(msgs, m)
+		return nil
+	})
+	return
+}
+
+// CourseNames of stored marks of user, all users when userID is empty
+func (s *Store) CourseNames(ctx context.Context, userID string) (names []string, err error) {
+	where, args := Query{UserID: userID}.where(true)
+	err = s.query(ctx, `SELECT DISTINCT trim(course_name) AS name FROM marks`+where+` ORDER BY name`,
+		args, func(rows *sql.Rows) error {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			names = append(names, name)
+			return nil
+		})
+	return
+}
+
+// Courses ordered by name
+func (s *Store) Courses(ctx context.Context) (courses []dnevnik76.Course, err error) {
+	err = s.query(ctx, `SELECT id, name FROM courses ORDER BY name`, nil, func(rows *sql.Rows) error {
+		var c dnevnik76.Course
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return err
+		}
+		courses = append(courses, c)
+		return nil
+	})
+	return
+}
+
+// Teachers of school ordered by name
+func (s *Store) Teachers(ctx context.Context, schoolID int64) (teachers []dnevnik76.Teacher, err error) {
+	err = s.query(ctx, `SELECT id, user_id, school_id, full_name, course_id, course_name FROM teachers
+		WHERE school_id = ? ORDER BY full_name, course_name`, []interface{}{schoolID}, func(rows *sql.Rows) error {
+		var t dnevnik76.Teacher
+		if err := rows.Scan(&t.ID, &t.UserID, &t.SchoolID, &t.FullName, &t.CourseID, &t.CourseName); err != nil {
+			return err
+		}
+		teachers = append(teachers, t)
+		return nil
+	})
+	return
+}
+
+// Periods of school for academic year starting in sYear, ordered by start date
+func (s *Store) Periods(ctx context.Context, schoolID int64, sYear int) (periods dnevnik76.Periods, err error) {
+	err = s.query(ctx, `SELECT school_id, s_year, e_year, name, period, start_date, end_date FROM periods
+		WHERE school_id = ? AND s_year = ? ORDER BY start_date, end_date`, []interface{}{schoolID, sYear}, func(rows *sql.Rows) error {
+		var p dnevnik76.Lperiod
+		var start, end int64
+		if err := rows.Scan(&p.SchoolID, &p.SYear, &p.EYear, &p.Name, &p.Period, &start, &end); err != nil {
+			return err
+		}
+		p.Start, p.End = fromUnix(start), fromUnix(end)
+		periods = append(periods, p)
+		return nil
+	})
+	return
+}
+
+// Regions ordered by name
+func (s *Store) Regions(ctx context.Context) (regions []dnevnik76.Region, err error) {
+	err = s.query(ctx, `SELECT id, name FROM regions ORDER BY name`, nil, func(rows *sql.Rows) error {
+		var r dnevnik76.Region
+		if err := rows.Scan(&r.ID, &r.Name); err != nil {
+			return err
+		}
+		regions = append(regions, r)
+		return nil
+	})
+	return
+}
+
+// Schools of region ordered by name
+func (s *Store) Schools(ctx context.Context, regionID int64) (schools []dnevnik76.School, err error) {
+	err = s.query(ctx, `SELECT id, region_id, name, type FROM schools WHERE region_id = ? ORDER BY name`,
+		[]interface{}{regionID}, func(rows *sql.Rows) error {
+			var sc dnevnik76.School
+			if err := rows.Scan(&sc.ID, &sc.RegionID, &sc.Name, &sc.Type); err != nil {
+				return err
+			}
+			schools = append(schools, sc)
+			return nil
+		})
+	return
+}
+
+func (s *Store) query(ctx context.Context, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,243 @@
+// Package store keeps diary records in an embedded SQLite database
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	// pure Go SQLite driver registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// migrations of the schema, the version is the index plus one, never edit applied ones
+var migrations = []string{
+	`CREATE TABLE regions (
+		id   INTEGER PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE schools (
+		id        INTEGER PRIMARY KEY,
+		region_id INTEGER NOT NULL DEFAULT 0,
+		name      TEXT NOT NULL DEFAULT '',
+		type      TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX schools_region ON schools (region_id);
+	CREATE TABLE courses (
+		id   INTEGER PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE teachers (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id     TEXT NOT NULL DEFAULT '',
+		school_id   INTEGER NOT NULL DEFAULT 0,
+		full_name   TEXT NOT NULL DEFAULT '',
+		course_id   TEXT NOT NULL DEFAULT '',
+		course_name TEXT NOT NULL DEFAULT '',
+		UNIQUE (school_id, user_id, course_name)
+	);
+	CREATE TABLE periods (
+		school_id  INTEGER NOT NULL DEFAULT 0,
+		s_year     INTEGER NOT NULL DEFAULT 0,
+		e_year     INTEGER NOT NULL DEFAULT 0,
+		name       TEXT NOT NULL DEFAULT '',
+		period     TEXT NOT NULL,
+		start_date INTEGER NOT NULL DEFAULT 0,
+		end_date   INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (school_id, s_year, period)
+	);
+	CREATE TABLE marks (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id     TEXT NOT NULL DEFAULT '',
+		school_id   INTEGER NOT NULL DEFAULT 0,
+		course_id   INTEGER NOT NULL DEFAULT 0,
+		course_name TEXT NOT NULL DEFAULT '',
+		subject     TEXT NOT NULL DEFAULT '',
+		homework    TEXT NOT NULL DEFAULT '',
+		grades      TEXT NOT NULL DEFAULT '',
+		dow         TEXT NOT NULL DEFAULT '',
+		date        INTEGER NOT NULL DEFAULT 0,
+		s_year      SMALLINT NOT NULL DEFAULT 0,
+		e_year      SMALLINT NOT NULL DEFAULT 0,
+		quarter     SMALLINT NOT NULL DEFAULT 0,
+		annual      BOOLEAN NOT NULL DEFAULT 0,
+		position    INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (user_id, school_id, s_year, quarter, annual, course_name, date, position)
+	);
+	CREATE INDEX marks_date ON marks (date);
+	CREATE INDEX marks_course ON marks (course_name);
+	CREATE TABLE homework (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		school_id   INTEGER NOT NULL DEFAULT 0,
+		class_id    INTEGER NOT NULL DEFAULT 0,
+		date        INTEGER NOT NULL DEFAULT 0,
+		dow         TEXT NOT NULL DEFAULT '',
+		course_id   INTEGER NOT NULL DEFAULT 0,
+		course_name TEXT NOT NULL DEFAULT '',
+		homework    TEXT NOT NULL DEFAULT '',
+		subject     TEXT NOT NULL DEFAULT '',
+		position    INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (school_id, class_id, date, course_name, position)
+	);
+	CREATE INDEX homework_date ON homework (date);
+	CREATE TABLE messages (
+		id        INTEGER NOT NULL,
+		user_id   TEXT NOT NULL DEFAULT '',
+		date      INTEGER NOT NULL DEFAULT 0,
+		sender    TEXT NOT NULL DEFAULT '',
+		is_unread BOOLEAN NOT NULL DEFAULT 0,
+		subject   TEXT NOT NULL DEFAULT '',
+		body      TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (user_id, id)
+	);
+	CREATE INDEX messages_date ON messages (date);`,
+	`CREATE TABLE mark_versions (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		mark_key    TEXT NOT NULL,
+		user_id     TEXT NOT NULL DEFAULT '',
+		school_id   INTEGER NOT NULL DEFAULT 0,
+		course_id   INTEGER NOT NULL DEFAULT 0,
+		course_name TEXT NOT NULL DEFAULT '',
+		date        INTEGER NOT NULL DEFAULT 0,
+		kind        SMALLINT NOT NULL,
+		mark        TEXT NOT NULL,
+		first_seen  INTEGER NOT NULL,
+		last_seen   INTEGER NOT NULL
+	);
+	CREATE INDEX mark_versions_key ON mark_versions (mark_key, id);
+	CREATE INDEX mark_versions_course ON mark_versions (user_id, course_name);
+	CREATE INDEX mark_versions_date ON mark_versions (user_id, date);`,
+	`ALTER TABLE marks ADD COLUMN term SMALLINT NOT NULL DEFAULT 0;`,
+	`CREATE TABLE marks_keyed (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		mark_key    TEXT NOT NULL UNIQUE,
+		user_id     TEXT NOT NULL DEFAULT '',
+		school_id   INTEGER NOT NULL DEFAULT 0,
+		course_id   INTEGER NOT NULL DEFAULT 0,
+		course_name TEXT NOT NULL DEFAULT '',
+		subject     TEXT NOT NULL DEFAULT '',
+		homework    TEXT NOT NULL DEFAULT '',
+		grades      TEXT NOT NULL DEFAULT '',
+		dow         TEXT NOT NULL DEFAULT '',
+		date        INTEGER NOT NULL DEFAULT 0,
+		s_year      SMALLINT NOT NULL DEFAULT 0,
+		e_year      SMALLINT NOT NULL DEFAULT 0,
+		quarter     SMALLINT NOT NULL DEFAULT 0,
+		term        SMALLINT NOT NULL DEFAULT 0,
+		annual      BOOLEAN NOT NULL DEFAULT 0,
+		position    INTEGER NOT NULL DEFAULT 0
+	);
+	-- keys as built by Mark.Key, the latest of the rows that become the same mark is kept
+	INSERT OR IGNORE INTO marks_keyed (id, mark_key, user_id, school_id, course_id, course_name, subject, homework,
+			grades, dow, date, s_year, e_year, quarter, term, annual, position)
+		SELECT id, 'mark/' || user_id || '/' || school_id || '/' || s_year || '/' ||
+				CASE WHEN annual THEN 'year'
+					WHEN quarter > 0 THEN CASE term WHEN 2 THEN 'h' WHEN 3 THEN 't' ELSE 'q' END || quarter
+					ELSE 'lesson' END || '/' ||
+				CASE course_id WHEN 0 THEN trim(course_name) ELSE course_id END || '/' ||
+				CASE date WHEN 0 THEN '' ELSE strftime('%Y-%m-%d', date, 'unixepoch', 'localtime') END || '/' ||
+				position,
+			user_id, school_id, course_id, course_name, subject, homework,
+			grades, dow, date, s_year, e_year, quarter, term, annual, position
+		FROM marks ORDER BY id DESC;
+	DROP TABLE marks;
+	ALTER TABLE marks_keyed RENAME TO marks;
+	CREATE INDEX marks_date ON marks (date);
+	CREATE INDEX marks_course ON marks (course_name);`,
+}
+
+// Store of diary records
+type Store struct {
+	db *sql.DB
+}
+
+// Open database at path and migrate it to the latest schema version
+func Open(ctx context.Context, path string) (s *Store, err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return
+	}
+	// single connection keeps in-memory databases shared and avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	s = &Store{db: db}
+	if _, err = db.ExecContext(ctx, "PRAGMA busy_timeout = 5000"); err == nil {
+		err = s.migrate(ctx)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return
+}
+
+// Close database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB to run custom queries
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Version of the database schema
+func (s *Store) Version(ctx context.Context) (v int, err error) {
+	err = s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&v)
+	return
+}
+
+// SchemaVersion the store migrates to
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+func (s *Store) migrate(ctx context.Context) error {
+	v, err := s.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if v > len(migrations) {
+		return fmt.Errorf("store: schema version %d is newer than supported %d", v, len(migrations))
+	}
+	for ; v < len(migrations); v++ {
+		err = s.tx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migrations[v]); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", v+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("store: migration %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) tx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// unix time of t, zero time is stored as 0 to keep natural keys unique
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(v int64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(v, 0)
+}
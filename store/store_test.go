@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+func day(m time.Month, d int) time.Time {
+	return time.Date(2022, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func open(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestOpen_Migrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "diary.db")
+	s := open(t, path)
+	if v, err := s.Version(ctx); err != nil || v != SchemaVersion() {
+		t.Fatalf("version %d (%v), want %d", v, err, SchemaVersion())
+	}
+	s.Close()
+
+	if _, err := open(t, path).Version(ctx); err != nil {
+		t.Fatalf("reopen - %v", err)
+	}
+	if _, err := s.db.Exec("SELECT 1"); err == nil {
+		t.Error("closed store still usable")
+	}
+}
+
+func TestOpen_MigrateMarkKeys(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "diary.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:3] {
+		if _, err = db.Exec(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.Exec(`PRAGMA user_version = 3;
+		INSERT INTO marks (user_id, school_id, course_id, course_name, date, s_year, grades) VALUES
+			('u', 1, 0, ' Алгебра', ?, 2022, '5'),
+			('u', 1, 7, 'Физика', 0, 2022, '3'),
+			('u', 1, 7, 'Физика и астрономия', 0, 2022, '4');
+		UPDATE marks SET quarter = 1, term = 2 WHERE course_id = 7`, unix(day(9, 5)))
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := open(t, path)
+	var keys []string
+	err = s.query(ctx, "SELECT mark_key FROM marks ORDER BY id", nil, func(rows *sql.Rows) error {
+		var k string
+		keys = append(keys, k)
+		return rows.Scan(&keys[len(keys)-1])
+	})
+	want := []string{"mark/u/1/2022/lesson/Алгебра/2022-09-05/0", "mark/u/1/2022/h1/7//0"}
+	if err != nil || !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys - %q (%v), want %q", keys, err, want)
+	}
+	marks, err := s.Marks(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range marks {
+		if m.CourseID == 7 && m.CourseName != "Физика и астрономия" {
+			t.Errorf("latest of the same marks is not kept - %v", m)
+		}
+	}
+}
+
+func TestStore_PutMarks(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, ":memory:")
+	marks := []dnevnik76.Mark{
+		{UserID: "u", SchoolID: 1, SYear: 2022, CourseName: "Алгебра", Date: day(9, 5), Grade: []int8{5, 4}},
+		{UserID: "u", SchoolID: 1, SYear: 2022, CourseName: "Алгебра", Date: day(9, 5), Grade: []int8{3}},
+		{UserID: "u", SchoolID: 1, SYear: 2022, CourseName: "Физика", Date: day(9, 20), Subject: "Контрольная работа"},
+		{UserID: "u", SchoolID: 1, SYear: 2022, CourseName: "Физика", CourseID: 7, Quarter: 1, Grade: []int8{4}},
+	}
+	if err := s.PutMarks(ctx, marks); err != nil {
+		t.Fatal(err)
+	}
+	if marks[0].ID == 0 || marks[0].ID == marks[1].ID {
+		t.Fatalf("ids - %d, %d", marks[0].ID, marks[1].ID)
+	}
+
+	updated := append([]dnevnik76.Mark(nil), marks...)
+	updated[2].Grade = []int8{5}
+	updated[2].ID = 0
+	if err := s.PutMarks(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated[2].ID != marks[2].ID {
+		t.Errorf("upsert id - %d, want %d", updated[2].ID, marks[2].ID)
+	}
+	spaced := []dnevnik76.Mark{updated[2]}
+	spaced[0].CourseName, spaced[0].ID = "Физика ", 0
+	if err := s.PutMarks(ctx, spaced); err != nil {
+		t.Fatal(err)
+	}
+	if spaced[0].ID != marks[2].ID {
+		t.Errorf("course name with spaces upserted as id %d, want %d", spaced[0].ID, marks[2].ID)
+	}
+	// the course id keys final marks as in Mark.Key, a half-year is not a quarter
+	renamed := []dnevnik76.Mark{updated[3], updated[3]}
+	renamed[0].CourseName, renamed[0].ID = "Физика и астрономия", 0
+	renamed[1].Term, renamed[1].ID = dnevnik76.PeriodHalfYear, 0
+	if err := s.PutMarks(ctx, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if renamed[0].ID != marks[3].ID || renamed[1].ID == marks[3].ID {
+		t.Errorf("final mark ids - %d, %d, want %d and another", renamed[0].ID, renamed[1].ID, marks[3].ID)
+	}
+
+	all, err := s.Marks(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("marks - %d, want 5", len(all))
+	}
+	if !all[0].Date.IsZero() || all[0].Quarter != 1 {
+		t.Errorf("final mark first - %v", all[0])
+	}
+
+	got, err := s.Marks(ctx, Query{From: day(9, 1), To: day(9, 20), Course: " Физика"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Grade, []int8{5}) || !got[0].Date.Equal(day(9, 20)) {
+		t.Errorf("physics - %v", got)
+	}
+
+	names, err := s.CourseNames(ctx, "u")
+	if err != nil || !reflect.DeepEqual(names, []string{"Алгебра", "Физика", "Физика и астрономия"}) {
+		t.Errorf("course names - %v (%v)", names, err)
+	}
+}
+
+func TestStore_PutMessages(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, ":memory:")
+	full := []dnevnik76.Message{{ID: 1, UserID: "u", Date: day(9, 1), Subject: "Собрание", Body: "В 18:00", IsUnread: true}}
+	if err := s.PutMessages(ctx, full); err != nil {
+		t.Fatal(err)
+	}
+	listed := []dnevnik76.Message{
+		{ID: 1, UserID: "u", Date: day(9, 1), Subject: "Собрание"},
+		{ID: 2, UserID: "u", Date: day(9, 3), Subject: "Экскурсия"},
+	}
+	if err := s.PutMessages(ctx, listed); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := s.Messages(ctx, Query{UserID: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != 2 || msgs[1].Body != "В 18:00" || msgs[1].IsUnread {
+		t.Errorf("messages - %v", msgs)
+	}
+}
+
+func TestStore_Reference(t *testing.T) {
+	ctx := context.Background()
+	s := open(t, ":memory:")
+	if err := s.PutRegions(ctx, []dnevnik76.Region{{ID: 2, Name: "Ярославль"}, {ID: 1, Name: "Рыбинск"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutSchools(ctx, []dnevnik76.School{{ID: 10, RegionID: 2, Name: "Школа №1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutCourses(ctx, []dnevnik76.Course{{ID: 3, Name: "Химия"}}); err != nil {
+		t.Fatal(err)
+	}
+	teachers := []dnevnik76.Teacher{{UserID: "ivanova", SchoolID: 10, FullName: " Иванова А.А. ", CourseName: "Химия"}}
+	if err := s.PutTeachers(ctx, teachers); err != nil {
+		t.Fatal(err)
+	}
+	periods := []dnevnik76.Lperiod{
+		{SchoolID: 10, SYear: 2022, Name: "2 четверть", Period: "q2", Start: day(11, 7), End: day(12, 28)},
+		{SchoolID: 10, SYear: 2022, Name: "1 четверть", Period: "q1", Start: day(9, 1), End: day(10, 28)},
+	}
+	if err := s.PutPeriods(ctx, periods); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutHomework(ctx, []dnevnik76.Homework{{SchoolID: 10, ClassID: 5, Date: day(9, 6), CourseName: "Химия", Homework: "§1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	regions, _ := s.Regions(ctx)
+	schools, _ := s.Schools(ctx, 2)
+	courses, _ := s.Courses(ctx)
+	ts, _ := s.Teachers(ctx, 10)
+	ps, _ := s.Periods(ctx, 10, 2022)
+	hws, _ := s.Homework(ctx, Query{From: day(9, 6), To: day(9, 6)})
+	if len(regions) != 2 || regions[0].ID != 1 || len(schools) != 1 || len(courses) != 1 {
+		t.Errorf("regions %v, schools %v, courses %v", regions, schools, courses)
+	}
+	if len(ts) != 1 || ts[0].ID != teachers[0].ID || ts[0].FullName != "Иванова А.А." {
+		t.Errorf("teachers - %v", ts)
+	}
+	if len(ps) != 2 || ps[0].Period != "q1" || !ps[1].End.Equal(day(12, 28)) {
+		t.Errorf("periods - %v", ps)
+	}
+	if len(hws) != 1 || hws[0].Homework != "§1" {
+		t.Errorf("homework - %v", hws)
+	}
+}
@@ -0,0 +1,180 @@
+// Package store upserts
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+// PutRegions to insert or update regions by id
+func (s *Store) PutRegions(ctx context.Context, regions []dnevnik76.Region) error {
+	return s.exec(ctx, `INSERT INTO regions (id, name) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name`,
+		len(regions), func(i int) []interface{} {
+			r := regions[i]
+			return []interface{}{r.ID, r.Name}
+		})
+}
+
+// PutSchools to insert or update schools by id
+func (s *Store) PutSchools(ctx context.Context, schools []dnevnik76.School) error {
+	return s.exec(ctx, `INSERT INTO schools (id, region_id, name, type) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET region_id = excluded.region_id, name = excluded.name, type = excluded.type`,
+		len(schools), func(i int) []interface{} {
+			sc := schools[i]
+			return []interface{}{sc.ID, sc.RegionID, sc.Name, sc.Type}
+		})
+}
+
+// PutCourses to insert or update courses by id
+func (s *Store) PutCourses(ctx context.Context, courses []dnevnik76.Course) error {
+	return s.exec(ctx, `INSERT INTO courses (id, name) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name`,
+		len(courses), func(i int) []interface{} {
+			c := courses[i]
+			return []interface{}{c.ID, c.Name}
+		})
+}
+
+// PutPeriods to insert or update periods by school, year and period
+func (s *Store) PutPeriods(ctx context.Context, periods []dnevnik76.Lperiod) error {
+	return s.exec(ctx, `INSERT INTO periods (school_id, s_year, e_year, name, period, start_date, end_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (school_id, s_year, period) DO UPDATE SET
+			e_year = excluded.e_year, name = excluded.name,
+			start_date = excluded.start_date, end_date = excluded.end_date`,
+		len(periods), func(i int) []interface{} {
+			p := periods[i]
+			return []interface{}{p.SchoolID, p.SYear, p.EYear, p.Name, p.Period, unix(p.Start), unix(p.End)}
+		})
+}
+
+// PutTeachers to insert or update teachers by school, user and course, IDs are set
+func (s *Store) PutTeachers(ctx context.Context, teachers []dnevnik76.Teacher) error {
+	return s.returning(ctx, `INSERT INTO teachers (user_id, school_id, full_name, course_id, course_name)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (school_id, user_id, course_name) DO UPDATE SET
+			full_name = excluded.full_name, course_id = excluded.course_id
+		RETURNING id`,
+		len(teachers), func(i int) ([]interface{}, *int64) {
+			t := &teachers[i]
+			return []interface{}{t.UserID, t.SchoolID, strings.TrimSpace(t.FullName), t.CourseID, t.CourseName}, &t.ID
+		})
+}
+
+// PutMarks to insert or update marks by Mark.Key, IDs are set
+func (s *Store) PutMarks(ctx context.Context, marks []dnevnik76.Mark) error {
+	pos := markPositions(marks)
+	return s.returning(ctx, `INSERT INTO marks (mark_key, user_id, school_id, course_id, course_name, subject, homework,
+			grades, dow, date, s_year, e_year, quarter, term, annual, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (mark_key) DO UPDATE SET
+			course_id = excluded.course_id, course_name = excluded.course_name,
+			subject = excluded.subject, homework = excluded.homework,
+			grades = excluded.grades, dow = excluded.dow, e_year = excluded.e_year, term = excluded.term
+		RETURNING id`,
+		len(marks), func(i int) ([]interface{}, *int64) {
+			m := &marks[i]
+			k := *m
+			k.Position = pos[i]
+			return []interface{}{k.Key(), m.UserID, m.SchoolID, m.CourseID, strings.TrimSpace(m.CourseName), m.Subject, m.HomeWork,
+				formatGrades(m.Grade), m.DayOfWeek, unix(m.Date), m.SYear, m.EYear, m.Quarter, m.Term, m.Annual, pos[i]}, &m.ID
+		})
+}
+
+// PutHomework to insert or update homework by school, class, date, course
+// and position as in Homework.Key, IDs are set
+func (s *Store) PutHomework(ctx context.Context, hws []dnevnik76.Homework) error {
+	pos := dnevnik76.Positions(hws, dnevnik76.Homework.Key, func(h *dnevnik76.Homework) *int { return &h.Position })
+	return s.returning(ctx, `INSERT INTO homework (school_id, class_id, date, dow, course_id, course_name,
+			homework, subject, position)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (school_id, class_id, date, course_name, position) DO UPDATE SET
+			dow = excluded.dow, course_id = excluded.course_id,
+			homework = excluded.homework, subject = excluded.subject
+		RETURNING id`,
+		len(hws), func(i int) ([]interface{}, *int64) {
+			h := &hws[i]
+			return []interface{}{h.SchoolID, h.ClassID, unix(h.Date), h.DayOfWeek, h.CourseID, h.CourseName,
+				h.Homework, h.Subject, pos[i]}, &h.ID
+		})
+}
+
+// PutMessages to insert or update messages by user and id,
+// a stored body is kept when the new one is empty as message lists come without bodies
+func (s *Store) PutMessages(ctx context.Context, msgs []dnevnik76.Message) error {
+	return s.exec(ctx, `INSERT INTO messages (id, user_id, date, sender, is_unread, subject, body)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, id) DO UPDATE SET
+			date = excluded.date, sender = excluded.sender, is_unread = excluded.is_unread,
+			subject = excluded.subject,
+			body = CASE WHEN excluded.body = '' THEN messages.body ELSE excluded.body END`,
+		len(msgs), func(i int) []interface{} {
+			m := msgs[i]
+			return []interface{}{m.ID, m.UserID, unix(m.Date), m.From, m.IsUnread, m.Subject, m.Body}
+		})
+}
+
+func (s *Store) exec(ctx context.Context, query string, n int, args func(i int) []interface{}) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for i := 0; i < n; i++ {
+			if _, err = stmt.ExecContext(ctx, args(i)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) returning(ctx context.Context, query string, n int, args func(i int) ([]interface{}, *int64)) error {
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for i := 0; i < n; i++ {
+			a, id := args(i)
+			if err = stmt.QueryRowContext(ctx, a...).Scan(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// markPositions of marks as in Mark.Key
+func markPositions(marks []dnevnik76.Mark) []int {
+	return dnevnik76.Positions(marks, dnevnik76.Mark.Key, func(m *dnevnik76.Mark) *int { return &m.Position })
+}
+
+func formatGrades(grades []int8) string {
+	parts := make([]string, len(grades))
+	for i, g := range grades {
+		parts[i] = strconv.Itoa(int(g))
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseGrades(s string) (grades []int8, err error) {
+	if s == "" {
+		return
+	}
+	for _, p := range strings.Split(s, ",") {
+		var g int
+		if g, err = strconv.Atoi(p); err != nil {
+			return nil, err
+		}
+		grades = append(grades, int8(g))
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package dnevnik76
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/bvp/dnevnik76-api/cassette"
+)
+
+func TestClient_SetTransport(t *testing.T) {
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div id="content"><table class="list"><tbody><tr><td></td>
+			<td>Иванова Мария Петровна</td><td><b>Математика</b></td>
+			<td class="action_links"><a class="mailto" href="/messages/new/?to=ivanova@760215"></a></td></tr></tbody></table></div>`)
+	}))
+	dir := t.TempDir()
+	rec, err := cassette.New(dir, cassette.Record, cli.Transport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.SetTransport(rec)
+	recorded, err := cli.GetTeachers()
+	if err != nil || len(recorded) != 1 {
+		t.Fatalf("recorded %v (%v)", recorded, err)
+	}
+
+	offline := newFakeClient(t, http.NotFoundHandler())
+	rep, err := cassette.New(dir, cassette.Replay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline.SetTransport(rep)
+	replayed, err := offline.GetTeachers()
+	if err != nil || !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed %v (%v)", replayed, err)
+	}
+	if _, err = offline.GetMessages(); err == nil {
+		t.Error("messages were not recorded")
+	}
+}
+
+func TestClient_LoginSchoolName(t *testing.T) {
+	pages := map[string]string{
+		"/accounts/login/": `<form class="login__form"><input name="csrfmiddlewaretoken" value="t"/></form>`,
+		"/homework/": `<body onload="loadSubjects('/ajax/subj/121', true)"><div id="auth_info"><span id="role">Учащийся (7А)</span></div>
+			<div id="eduyear"><span id="curedy">2022-2023 учебный год</span></div></body>`,
+		"/ajax/school/2/": `<select><optgroup label="Школы"><option value="760215">Школа № 83</option></optgroup></select>`,
+	}
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	cli.CurrentInfo.RegionID = 2
+	if err := cli.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if cli.CurrentInfo.SchoolName != "Школа № 83" || cli.CurrentInfo.ClassID != 121 {
+		t.Errorf("info - %+v", cli.CurrentInfo)
+	}
+}
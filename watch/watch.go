@@ -0,0 +1,261 @@
+// Package watch polls the diary and reports new marks, messages and homework
+package watch
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+	"github.com/bvp/dnevnik76-api/snapshot"
+)
+
+// Source of diary records, usually *dnevnik76.Client
+type Source interface {
+	GetMessagesCount() (unread int, total int, err error)
+	GetMessages() ([]dnevnik76.Message, error)
+	GetMarksCurrent() ([]dnevnik76.Mark, error)
+	GetHomework() ([]dnevnik76.Homework, error)
+}
+
+// EventType of watcher event
+type EventType int
+
+const (
+	// NewMark is a new or corrected mark
+	NewMark EventType = iota + 1
+	// NewMessage is a message not seen before
+	NewMessage
+	// NewHomework is a homework not seen before
+	NewHomework
+)
+
+func (t EventType) String() string {
+	return [...]string{"", "mark", "message", "homework"}[t]
+}
+
+// MarshalText to encode event type by name
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText to decode event type by name
+func (t *EventType) UnmarshalText(text []byte) error {
+	for _, et := range []EventType{NewMark, NewMessage, NewHomework} {
+		if et.String() == string(text) {
+			*t = et
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", text)
+}
+
+// Event reported by watcher, only the field matching Type is set
+type Event struct {
+	Type     EventType           `json:"type"`
+	Time     time.Time           `json:"time"`
+	Mark     *dnevnik76.Mark     `json:"mark,omitempty"`
+	Message  *dnevnik76.Message  `json:"message,omitempty"`
+	Homework *dnevnik76.Homework `json:"homework,omitempty"`
+}
+
+// Watcher polls source on its own schedule for every kind of records.
+// The first poll only remembers the current state.
+type Watcher struct {
+	Source Source
+	// MessagesInterval between cheap unread counter checks
+	MessagesInterval time.Duration
+	// MarksInterval between marks checks
+	MarksInterval time.Duration
+	// HomeworkInterval between homework checks
+	HomeworkInterval time.Duration
+	// Jitter is the fraction of interval added or subtracted randomly
+	Jitter float64
+	// MaxBackoff limits the delay after repeated errors
+	MaxBackoff time.Duration
+	// OnError is called for every failed poll
+	OnError func(error)
+
+	// polling lets one poller at a time call the source, see dnevnik76.Locked
+	polling  sync.Mutex
+	mu       sync.Mutex
+	handler  func(Event)
+	unread   int
+	total    int
+	messages map[int64]bool
+	marks    []dnevnik76.Mark
+	homework []dnevnik76.Homework
+}
+
+// New watcher with default intervals
+func New(src Source) *Watcher {
+	return &Watcher{
+		Source:           src,
+		MessagesInterval: time.Minute,
+		MarksInterval:    15 * time.Minute,
+		HomeworkInterval: 30 * time.Minute,
+		Jitter:           0.1,
+		MaxBackoff:       time.Hour,
+	}
+}
+
+// Run to poll until ctx is done, calling handler for every event
+func (w *Watcher) Run(ctx context.Context, handler func(Event)) error {
+	w.handler = handler
+	w.unread, w.total = -1, -1
+
+	var wg sync.WaitGroup
+	pollers := []struct {
+		interval time.Duration
+		poll     func(first bool) error
+	}{
+		{w.MessagesInterval, w.pollMessages},
+		{w.MarksInterval, w.pollMarks},
+		{w.HomeworkInterval, w.pollHomework},
+	}
+	for _, p := range pollers {
+		wg.Add(1)
+		go func(interval time.Duration, poll func(bool) error) {
+			defer wg.Done()
+			w.loop(ctx, interval, poll)
+		}(p.interval, p.poll)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Watch to poll until ctx is done, delivering events through the channel
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		w.Run(ctx, func(e Event) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events
+}
+
+func (w *Watcher) loop(ctx context.Context, interval time.Duration, poll func(first bool) error) {
+	first, failures := true, 0
+	for {
+		w.polling.Lock()
+		err := poll(first)
+		w.polling.Unlock()
+		if err != nil {
+			failures++
+			if w.OnError != nil {
+				w.OnError(err)
+			}
+		} else {
+			first, failures = false, 0
+		}
+
+		t := time.NewTimer(w.delay(interval, failures))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// delay before the next poll with backoff for failures and jitter
+func (w *Watcher) delay(interval time.Duration, failures int) time.Duration {
+	d := interval
+	for i := 0; i < failures && (w.MaxBackoff == 0 || d < w.MaxBackoff); i++ {
+		d *= 2
+	}
+	if w.MaxBackoff > 0 && d > w.MaxBackoff {
+		d = w.MaxBackoff
+	}
+	if w.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * w.Jitter * float64(d))
+	}
+	return d
+}
+
+func (w *Watcher) emit(e Event) {
+	e.Time = time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.handler != nil {
+		w.handler(e)
+	}
+}
+
+// pollMessages checks the counters and fetches messages only when one changed,
+// a message read and a new one received between polls keep the unread count
+func (w *Watcher) pollMessages(first bool) error {
+	unread, total, err := w.Source.GetMessagesCount()
+	if err != nil {
+		return err
+	}
+	if unread == w.unread && total == w.total {
+		return nil
+	}
+
+	messages, err := w.Source.GetMessages()
+	if err != nil {
+		return err
+	}
+	w.unread, w.total = unread, total
+	known := w.messages
+	w.messages = make(map[int64]bool, len(messages))
+	for i := range messages {
+		m := &messages[i]
+		w.messages[m.ID] = true
+		if !first && !known[m.ID] {
+			w.emit(Event{Type: NewMessage, Message: m})
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) pollMarks(first bool) error {
+	marks, err := w.Source.GetMarksCurrent()
+	if err != nil {
+		return err
+	}
+	var graded []dnevnik76.Mark
+	for _, m := range marks {
+		if len(m.Grade) > 0 {
+			graded = append(graded, m)
+		}
+	}
+	changes := snapshot.Diff(&snapshot.Snapshot{Marks: w.marks}, &snapshot.Snapshot{Marks: graded})
+	w.marks = graded
+	if first {
+		return nil
+	}
+	for _, c := range changes.Marks {
+		if c.Op == snapshot.Added || c.Op == snapshot.Modified {
+			w.emit(Event{Type: NewMark, Mark: c.New})
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) pollHomework(first bool) error {
+	hws, err := w.Source.GetHomework()
+	if err != nil {
+		return err
+	}
+	changes := snapshot.Diff(&snapshot.Snapshot{Homework: w.homework}, &snapshot.Snapshot{Homework: hws})
+	w.homework = hws
+	if first {
+		return nil
+	}
+	for _, c := range changes.Homework {
+		if c.Op == snapshot.Added {
+			w.emit(Event{Type: NewHomework, Homework: c.New})
+		}
+	}
+	return nil
+}
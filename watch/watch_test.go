@@ -0,0 +1,204 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dnevnik76 "github.com/bvp/dnevnik76-api"
+)
+
+type fakeSource struct {
+	mu            sync.Mutex
+	unread        int
+	messages      []dnevnik76.Message
+	marks         []dnevnik76.Mark
+	homework      []dnevnik76.Homework
+	messagesCalls int
+	countErrors   int
+}
+
+func (f *fakeSource) GetMessagesCount() (int, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.countErrors > 0 {
+		f.countErrors--
+		return 0, 0, errors.New("unavailable")
+	}
+	return f.unread, len(f.messages), nil
+}
+
+func (f *fakeSource) GetMessages() ([]dnevnik76.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messagesCalls++
+	return append([]dnevnik76.Message(nil), f.messages...), nil
+}
+
+func (f *fakeSource) GetMarksCurrent() ([]dnevnik76.Mark, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]dnevnik76.Mark(nil), f.marks...), nil
+}
+
+func (f *fakeSource) GetHomework() ([]dnevnik76.Homework, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]dnevnik76.Homework(nil), f.homework...), nil
+}
+
+func (f *fakeSource) update(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn()
+}
+
+func next(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+	return Event{}
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	monday := time.Date(2022, time.September, 5, 0, 0, 0, 0, time.Local)
+	src := &fakeSource{
+		unread:   1,
+		messages: []dnevnik76.Message{{ID: 1, IsUnread: true}},
+		marks:    []dnevnik76.Mark{{CourseName: "Физика", Date: monday, Grade: []int8{4}}},
+		homework: []dnevnik76.Homework{{CourseName: "Физика", Date: monday, Homework: "§ 1"}},
+	}
+	w := New(src)
+	w.MessagesInterval = 5 * time.Millisecond
+	w.MarksInterval = 5 * time.Millisecond
+	w.HomeworkInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := w.Watch(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	src.update(func() {
+		if src.messagesCalls != 1 {
+			t.Errorf("messages fetched %d times with unchanged counter", src.messagesCalls)
+		}
+		src.unread = 2
+		src.messages = append(src.messages, dnevnik76.Message{ID: 2, IsUnread: true})
+	})
+	if e := next(t, events); e.Type != NewMessage || e.Message.ID != 2 {
+		t.Errorf("event - %+v", e)
+	}
+	// one message read and a new one received keep the unread count
+	src.update(func() {
+		src.messages[0].IsUnread = false
+		src.messages = append(src.messages, dnevnik76.Message{ID: 3, IsUnread: true})
+	})
+	if e := next(t, events); e.Type != NewMessage || e.Message.ID != 3 {
+		t.Errorf("event - %+v", e)
+	}
+
+	src.update(func() {
+		src.marks = append(src.marks, dnevnik76.Mark{CourseName: "Химия", Date: monday, Grade: []int8{5}})
+	})
+	if e := next(t, events); e.Type != NewMark || e.Mark.CourseName != "Химия" {
+		t.Errorf("event - %+v", e)
+	}
+
+	src.update(func() {
+		src.homework = append(src.homework, dnevnik76.Homework{CourseName: "Химия", Date: monday, Homework: "§ 2"})
+	})
+	if e := next(t, events); e.Type != NewHomework || e.Homework.Homework != "§ 2" {
+		t.Errorf("event - %+v", e)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatcher_Backoff(t *testing.T) {
+	w := New(&fakeSource{})
+	w.Jitter = 0
+	w.MaxBackoff = 5 * time.Minute
+	cases := map[int]time.Duration{0: time.Minute, 1: 2 * time.Minute, 2: 4 * time.Minute, 3: 5 * time.Minute, 10: 5 * time.Minute}
+	for failures, want := range cases {
+		if d := w.delay(time.Minute, failures); d != want {
+			t.Errorf("%d failures: %s, want %s", failures, d, want)
+		}
+	}
+
+	w.Jitter = 0.1
+	for i := 0; i < 100; i++ {
+		if d := w.delay(time.Minute, 0); d < 54*time.Second || d > 66*time.Second {
+			t.Fatalf("jittered delay %s", d)
+		}
+	}
+}
+
+func TestWatcher_Errors(t *testing.T) {
+	src := &fakeSource{countErrors: 2}
+	w := New(src)
+	w.MessagesInterval = time.Millisecond
+	w.MarksInterval = time.Hour
+	w.HomeworkInterval = time.Hour
+	w.Jitter = 0
+
+	errs := make(chan error, 10)
+	w.OnError = func(err error) { errs <- err }
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := w.Run(ctx, func(Event) {}); err != context.DeadlineExceeded {
+		t.Errorf("run - %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("errors - %d, want 2", len(errs))
+	}
+}
+
+// exclusiveSource fails when called concurrently, like the session info of *dnevnik76.Client races
+type exclusiveSource struct {
+	fakeSource
+	busy, overlaps int32
+}
+
+func (s *exclusiveSource) enter() func() {
+	if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+		atomic.AddInt32(&s.overlaps, 1)
+		return func() {}
+	}
+	time.Sleep(time.Millisecond)
+	return func() { atomic.StoreInt32(&s.busy, 0) }
+}
+
+func (s *exclusiveSource) GetMessagesCount() (int, int, error) {
+	defer s.enter()()
+	return s.fakeSource.GetMessagesCount()
+}
+
+func (s *exclusiveSource) GetMarksCurrent() ([]dnevnik76.Mark, error) {
+	defer s.enter()()
+	return s.fakeSource.GetMarksCurrent()
+}
+
+func (s *exclusiveSource) GetHomework() ([]dnevnik76.Homework, error) {
+	defer s.enter()()
+	return s.fakeSource.GetHomework()
+}
+
+func TestWatcher_SerializedSource(t *testing.T) {
+	src := &exclusiveSource{}
+	w := New(src)
+	w.MessagesInterval, w.MarksInterval, w.HomeworkInterval = time.Millisecond, time.Millisecond, time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w.Run(ctx, func(Event) {})
+	if n := atomic.LoadInt32(&src.overlaps); n != 0 {
+		t.Errorf("%d overlapping source calls", n)
+	}
+}
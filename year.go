@@ -0,0 +1,86 @@
+// Package dnevnik76 academic years
+package dnevnik76
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/bvp/dnevnik76-api/model"
+)
+
+const cookieEduYear = "edu_year"
+
+// EduYear is an academic year identified by the calendar year it starts in
+type EduYear = model.EduYear
+
+// CurrentEduYear is the academic year the site considers current
+const CurrentEduYear = model.CurrentEduYear
+
+// yearJar scopes the edu_year cookie to one academic year and keeps it out of the shared jar
+type yearJar struct {
+	jar  http.CookieJar
+	year EduYear
+}
+
+func (j yearJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if j.jar == nil {
+		return
+	}
+	var rest []*http.Cookie
+	for _, c := range cookies {
+		if c.Name != cookieEduYear {
+			rest = append(rest, c)
+		}
+	}
+	j.jar.SetCookies(u, rest)
+}
+
+func (j yearJar) Cookies(u *url.URL) (cookies []*http.Cookie) {
+	if j.jar != nil {
+		for _, c := range j.jar.Cookies(u) {
+			if c.Name != cookieEduYear {
+				cookies = append(cookies, c)
+			}
+		}
+	}
+	if j.year != CurrentEduYear {
+		cookies = append(cookies, &http.Cookie{Name: cookieEduYear, Value: strconv.Itoa(int(j.year))})
+	}
+	return
+}
+
+// ForYear to get a view of the client with every request scoped to academic year y.
+// The view shares session and caches with cli but never changes its state,
+// so both can be used concurrently.
+func (cli *Client) ForYear(y EduYear) (*Client, error) {
+	view := *cli
+	hc := *cli.http
+	jar := cli.http.Jar
+	if yj, ok := jar.(yearJar); ok {
+		jar = yj.jar
+	}
+	hc.Jar = yearJar{jar: jar, year: y}
+	view.http = &hc
+
+	if info, ok := cli.cache.getInfo(y); ok {
+		view.CurrentInfo = info
+		return &view, nil
+	}
+	if err := view.getCurrentInfo(); err != nil {
+		return nil, err
+	}
+	cli.cache.setInfo(y, view.CurrentInfo)
+	return &view, nil
+}
+
+// AvailableYears to get academic years offered by the #eduyear selector, newest first
+func (cli *Client) AvailableYears() (years []EduYear, err error) {
+	body, err := cli.fetch(urlHomework)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	years, issues, err := cli.selectors().ParseEduYears(body)
+	return years, cli.checked(issues, err)
+}
@@ -0,0 +1,66 @@
+package dnevnik76
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func homeworkPage(year int) string {
+	return fmt.Sprintf(`<html><body onload="loadSubjects('/ajax/subj/%d', true)">
+		<div id="auth_info"><span id="role">Учащийся (5А)</span></div>
+		<div id="eduyear"><span id="curedy">%d-%d учебный год</span>
+			<ul><li><a href="#">2022-2023</a></li><li><a href="#">2021-2022</a></li><li><a href="#">2020-2021</a></li></ul>
+		</div></body></html>`, 100+year%100, year, year+1)
+}
+
+func TestClient_ForYear(t *testing.T) {
+	var hits int32
+	cli := newFakeClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		year := 2022
+		if c, err := r.Cookie(cookieEduYear); err == nil {
+			fmt.Sscan(c.Value, &year)
+		}
+		fmt.Fprint(w, homeworkPage(year))
+	}))
+
+	var wg sync.WaitGroup
+	for _, y := range []EduYear{2020, 2021, 2022, 2020} {
+		wg.Add(1)
+		go func(y EduYear) {
+			defer wg.Done()
+			view, err := cli.ForYear(y)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if view.CurrentInfo.EduYear() != y || view.CurrentInfo.EduYearEnd != int(y)+1 {
+				t.Errorf("year %s: info %#v", y, view.CurrentInfo)
+			}
+		}(y)
+	}
+	wg.Wait()
+
+	for _, c := range cli.http.Jar.Cookies(u) {
+		if c.Name == cookieEduYear {
+			t.Errorf("shared jar got %s=%s", c.Name, c.Value)
+		}
+	}
+
+	n := atomic.LoadInt32(&hits)
+	view, _ := cli.ForYear(2021)
+	if atomic.LoadInt32(&hits) != n || view.CurrentInfo.ClassID != 121 {
+		t.Errorf("info for %s was not cached: %#v", EduYear(2021), view.CurrentInfo)
+	}
+
+	years, err := cli.AvailableYears()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(years) != "[2022-2023 2021-2022 2020-2021]" {
+		t.Errorf("years - %v", years)
+	}
+}